@@ -3,6 +3,8 @@ package checks
 import (
 	"fmt"
 	"slices"
+	"strconv"
+	"time"
 
 	"github.com/NETWAYS/go-check"
 	"github.com/NETWAYS/go-check/perfdata"
@@ -11,9 +13,57 @@ import (
 	"github.com/icinga/check-netgear/netgear"
 )
 
+// Thresholds are the warning and critical thresholds of an item, nil thresholds are not checked
+type Thresholds struct {
+	Warn *check.Threshold
+	Crit *check.Threshold
+}
+
+// Status returns the state of value
+func (t Thresholds) Status(value float64) int {
+	return utils.StatusByRange(value, t.Warn, t.Crit)
+}
+
+// Overrides maps items to thresholds replacing the global ones, e.g. sensor and fan descriptions or port numbers
+type Overrides map[string]Thresholds
+
+// For returns the thresholds of item, using global for thresholds that are not overridden
+func (o Overrides) For(item string, global Thresholds) Thresholds {
+	override, ok := o[item]
+	if !ok {
+		return global
+	}
+	if override.Warn == nil {
+		override.Warn = global.Warn
+	}
+	if override.Crit == nil {
+		override.Crit = global.Crit
+	}
+	return override
+}
+
+// CheckUptime creates a partialResult with the uptime, thresholds are in seconds. Thresholds like "3600:" alert when
+// the switch was rebooted recently.
+func CheckUptime(uptime time.Duration, noPerfdata bool, thresholds Thresholds) (*result.PartialResult, error) {
+	seconds := uptime.Seconds()
+	status := thresholds.Status(seconds)
+	partial := result.PartialResult{
+		Output: fmt.Sprintf("Uptime: %v", uptime),
+	}
+	if err := partial.SetState(status); err != nil {
+		return nil, err
+	}
+	if !noPerfdata {
+		partial.Perfdata.Add(&perfdata.Perfdata{
+			Label: "uptime", Value: seconds, Uom: "s", Warn: thresholds.Warn, Crit: thresholds.Crit, Min: 0,
+		})
+	}
+	return &partial, nil
+}
+
 // CheckCPU creates a partialResult with the CPU information
-func CheckCPU(cpuUsage float64, noPerfdata bool, warn float64, crit float64) (*result.PartialResult, error) {
-	status := utils.StatusByThreshold(cpuUsage, warn, crit)
+func CheckCPU(cpuUsage float64, noPerfdata bool, thresholds Thresholds) (*result.PartialResult, error) {
+	status := thresholds.Status(cpuUsage)
 	partial := result.PartialResult{
 		Output: fmt.Sprintf("CPU Usage: %.2f%%", cpuUsage),
 	}
@@ -21,14 +71,16 @@ func CheckCPU(cpuUsage float64, noPerfdata bool, warn float64, crit float64) (*r
 		return nil, err
 	}
 	if !noPerfdata {
-		partial.Perfdata.Add(&perfdata.Perfdata{Label: "CPU", Value: cpuUsage, Min: 0, Max: 100})
+		partial.Perfdata.Add(&perfdata.Perfdata{
+			Label: "CPU", Value: cpuUsage, Warn: thresholds.Warn, Crit: thresholds.Crit, Min: 0, Max: 100,
+		})
 	}
 	return &partial, nil
 }
 
 // CheckMemory creates a partialResult with the memory information
-func CheckMemory(memUsage float64, noPerfdata bool, warn float64, crit float64) (*result.PartialResult, error) {
-	status := utils.StatusByThreshold(memUsage, warn, crit)
+func CheckMemory(memUsage float64, noPerfdata bool, thresholds Thresholds) (*result.PartialResult, error) {
+	status := thresholds.Status(memUsage)
 	partial := result.PartialResult{
 		Output: fmt.Sprintf("RAM Usage: %.2f%%", memUsage),
 	}
@@ -36,30 +88,64 @@ func CheckMemory(memUsage float64, noPerfdata bool, warn float64, crit float64)
 		return nil, err
 	}
 	if !noPerfdata {
-		partial.Perfdata.Add(&perfdata.Perfdata{Label: "RAM", Value: memUsage, Min: 0, Max: 100})
+		partial.Perfdata.Add(&perfdata.Perfdata{
+			Label: "RAM", Value: memUsage, Warn: thresholds.Warn, Crit: thresholds.Crit, Min: 0, Max: 100,
+		})
 	}
 	return &partial, nil
 }
 
-// CheckTemperature creates a partialResult with the temperature information
-func CheckTemperature(sensors []netgear.SensorDetail, noPerfdata bool, warn float64, crit float64) (*result.PartialResult, error) {
+// CheckTemperature creates a partialResult with the temperature information. If warnPercent or critPercent are greater
+// than 0, they replace the global thresholds for sensors reporting a maximum temperature, relative to that maximum.
+// Overrides of a sensor take precedence over both.
+func CheckTemperature(sensors []netgear.SensorDetail, noPerfdata bool, global Thresholds, warnPercent float64, critPercent float64, overrides Overrides) (*result.PartialResult, error) {
 	partial := result.PartialResult{Output: "Temperature"}
 	worst := check.OK
 
 	for _, s := range sensors {
-		status := utils.StatusByThreshold(s.Temperature, warn, crit)
-		if status > worst {
-			worst = status
+		temperature, err := s.Temperature.Float64()
+		if err != nil {
+			sub, err := unknownResult(fmt.Sprintf("%s: %v", s.Description, err))
+			if err != nil {
+				return nil, err
+			}
+			worst = result.WorstState(worst, check.Unknown)
+			partial.AddSubcheck(sub)
+			continue
+		}
+
+		// sensors without a valid maximum temperature fall back to the absolute thresholds
+		maxTemp, err := s.MaxTemp.Float64()
+		if err != nil {
+			maxTemp = 0
+		}
+		sensorThresholds := global
+		if maxTemp > 0 && warnPercent > 0 {
+			sensorThresholds.Warn = utils.UpperBound(maxTemp * warnPercent / 100)
 		}
+		if maxTemp > 0 && critPercent > 0 {
+			sensorThresholds.Crit = utils.UpperBound(maxTemp * critPercent / 100)
+		}
+		sensorThresholds = overrides.For(s.Description, sensorThresholds)
+
+		status := sensorThresholds.Status(temperature)
+		worst = result.WorstState(worst, status)
 
 		sub := result.PartialResult{
-			Output: fmt.Sprintf("%s: %.1f°C", s.Description, s.Temperature),
+			Output: fmt.Sprintf("%s: %.1f°C", s.Description, temperature),
 		}
 		if err := sub.SetState(status); err != nil {
 			return nil, err
 		}
 		if !noPerfdata {
-			sub.Perfdata.Add(&perfdata.Perfdata{Label: s.Description, Value: s.Temperature, Min: 0})
+			p := &perfdata.Perfdata{
+				Label: s.Description, Value: temperature,
+				Warn: sensorThresholds.Warn, Crit: sensorThresholds.Crit, Min: 0,
+			}
+			if maxTemp > 0 {
+				p.Max = maxTemp
+			}
+			sub.Perfdata.Add(p)
 		}
 		partial.AddSubcheck(sub)
 	}
@@ -70,51 +156,139 @@ func CheckTemperature(sensors []netgear.SensorDetail, noPerfdata bool, warn floa
 	return &partial, nil
 }
 
-// CheckFans creates a partialResult with the fans information
-func CheckFans(noPerfdata bool, fanName string, fanSpeed float64, warn float64, crit float64) (*result.PartialResult, error) {
-	status := utils.StatusByThreshold(fanSpeed, warn, crit)
-
+// CheckFans creates a partialResult with the speed of every fan. Thresholds are ranges, so fans can be alerted on
+// when they are too slow. Stopped fans and fans the switch reports as failed are CRITICAL, fans that are not installed
+// are skipped.
+func CheckFans(fans []netgear.FanDetail, noPerfdata bool, global Thresholds, overrides Overrides) (*result.PartialResult, error) {
 	partial := result.PartialResult{Output: "Fans"}
-	sub := result.PartialResult{
-		Output: fmt.Sprintf("%s: %.0f RPM", fanName, fanSpeed),
-	}
-	if err := sub.SetState(status); err != nil {
-		return nil, err
-	}
-	if !noPerfdata {
-		sub.Perfdata.Add(&perfdata.Perfdata{Label: "Fan Speed", Value: fanSpeed, Min: 0})
+	worst := check.OK
+	labels := make(map[string]bool, len(fans))
+
+	for i, fan := range fans {
+		if !fan.Status.Present() {
+			sub := result.PartialResult{Output: fmt.Sprintf("%s: not present", fan.Description)}
+			if err := sub.SetState(check.OK); err != nil {
+				return nil, err
+			}
+			partial.AddSubcheck(sub)
+			continue
+		}
+
+		speed, err := fan.Speed.Float64()
+		if err != nil {
+			sub, err := unknownResult(fmt.Sprintf("%s: %v", fan.Description, err))
+			if err != nil {
+				return nil, err
+			}
+			worst = result.WorstState(worst, check.Unknown)
+			partial.AddSubcheck(sub)
+			continue
+		}
+		// the maximum speed is informational only, so an invalid value is ignored
+		maxSpeed, _ := fan.MaxSpeed.Float64()
+
+		thresholds := overrides.For(fan.Description, global)
+		status := thresholds.Status(speed)
+		output := fmt.Sprintf("%s: %.0f RPM", fan.Description, speed)
+		if maxSpeed > 0 {
+			output = fmt.Sprintf("%s: %.0f/%.0f RPM", fan.Description, speed, maxSpeed)
+		}
+		switch {
+		case fan.Status.Failed():
+			status = check.Critical
+			output += fmt.Sprintf(" (%s)", fan.Status)
+		case speed == 0:
+			status = check.Critical
+			output += " (stopped)"
+		}
+		worst = result.WorstState(worst, status)
+
+		sub := result.PartialResult{Output: output}
+		if err := sub.SetState(status); err != nil {
+			return nil, err
+		}
+		if !noPerfdata {
+			p := &perfdata.Perfdata{
+				Label: fanLabel(fan.Description, i, labels),
+				Value: speed, Warn: thresholds.Warn, Crit: thresholds.Crit, Min: 0,
+			}
+			if maxSpeed > 0 {
+				p.Max = maxSpeed
+			}
+			sub.Perfdata.Add(p)
+		}
+		partial.AddSubcheck(sub)
 	}
-	partial.AddSubcheck(sub)
-	if err := partial.SetState(status); err != nil {
+
+	if err := partial.SetState(worst); err != nil {
 		return nil, err
 	}
-
 	return &partial, nil
 }
 
-// CheckPorts creates a partialResult with the port information
-func CheckPorts(inRows, outRows []netgear.PortStatisticRow, portsToCheck []int, noPerfdata bool, warn float64, crit float64) (*result.PartialResult, error) {
+// fanLabel returns a unique perfdata label for the fan at index i, falling back to its position if the description is
+// empty or was already used
+func fanLabel(description string, i int, used map[string]bool) string {
+	label := description
+	if label == "" {
+		label = fmt.Sprintf("fan %d", i+1)
+	} else if used[label] {
+		label = fmt.Sprintf("%s %d", label, i+1)
+	}
+	used[label] = true
+	return label
+}
+
+// CheckPorts creates a partialResult with the port information. Ports without statistics in either inRows or outRows
+// are reported as UNKNOWN. Overrides are looked up by port number.
+func CheckPorts(inRows, outRows []netgear.PortStatisticRow, portsToCheck []int, noPerfdata bool, global Thresholds, overrides Overrides) (*result.PartialResult, error) {
 	overall := result.PartialResult{Output: "Ports Statistics"}
 	worst := check.OK
 
-	for i := range inRows {
-		in := inRows[i]
-		out := outRows[i]
+	findRow := func(rows []netgear.PortStatisticRow, port int) (netgear.PortStatisticRow, bool) {
+		i := slices.IndexFunc(rows, func(row netgear.PortStatisticRow) bool { return row.Port == port })
+		if i < 0 {
+			return netgear.PortStatisticRow{}, false
+		}
+		return rows[i], true
+	}
+
+	for _, port := range portsToCheck {
+		in, inFound := findRow(inRows, port)
+		out, outFound := findRow(outRows, port)
+
+		portCheck := result.PartialResult{Output: fmt.Sprintf("Port %v", port)}
 
-		if !slices.Contains(portsToCheck, in.Port) {
+		if !inFound || !outFound {
+			portCheck.Output = fmt.Sprintf("Port %v: no statistics returned by the device", port)
+			if err := portCheck.SetState(check.Unknown); err != nil {
+				return nil, err
+			}
+			worst = result.WorstState(worst, check.Unknown)
+			overall.AddSubcheck(portCheck)
 			continue
 		}
 
-		portCheck := result.PartialResult{Output: fmt.Sprintf("Port %v", in.Port)}
+		counters, err := float64Values(in.InDropPkts, in.InTotalPkts, out.OutDropPkts, out.OutTotalPkts)
+		if err != nil {
+			portCheck, err := unknownResult(fmt.Sprintf("Port %v: %v", port, err))
+			if err != nil {
+				return nil, err
+			}
+			worst = result.WorstState(worst, check.Unknown)
+			overall.AddSubcheck(portCheck)
+			continue
+		}
 
-		inLoss := utils.LossPercent(in.InDropPkts, in.InTotalPkts)
-		outLoss := utils.LossPercent(out.OutDropPkts, out.OutTotalPkts)
+		inLoss := utils.LossPercent(counters[0], counters[1])
+		outLoss := utils.LossPercent(counters[2], counters[3])
 
-		inStatus := utils.StatusByThreshold(inLoss, warn, crit)
-		outStatus := utils.StatusByThreshold(outLoss, warn, crit)
+		thresholds := overrides.For(strconv.Itoa(port), global)
+		inStatus := thresholds.Status(inLoss)
+		outStatus := thresholds.Status(outLoss)
 
 		portStatus := max(inStatus, outStatus)
-		worst = max(worst, portStatus)
+		worst = result.WorstState(worst, portStatus)
 
 		addPerfSubcheck := func(label string, loss float64, status int) error {
 			sub := result.PartialResult{
@@ -126,7 +300,7 @@ func CheckPorts(inRows, outRows []netgear.PortStatisticRow, portsToCheck []int,
 			if !noPerfdata {
 				sub.Perfdata.Add(&perfdata.Perfdata{
 					Label: fmt.Sprintf("port %v %s loss", in.Port, label),
-					Value: loss, Min: 0, Max: 100,
+					Value: loss, Warn: thresholds.Warn, Crit: thresholds.Crit, Min: 0, Max: 100,
 				})
 			}
 			portCheck.AddSubcheck(sub)
@@ -195,3 +369,26 @@ func CheckPoe(ports []netgear.PoePort, noPerfdata bool) (*result.PartialResult,
 	}
 	return &partial, nil
 }
+
+// unknownResult creates a partialResult in the UNKNOWN state, used for values the device reported in an unexpected
+// format
+func unknownResult(output string) (result.PartialResult, error) {
+	partial := result.PartialResult{Output: output}
+	if err := partial.SetState(check.Unknown); err != nil {
+		return partial, err
+	}
+	return partial, nil
+}
+
+// float64Values returns the values of all numbers or the first error
+func float64Values(numbers ...netgear.Number) ([]float64, error) {
+	values := make([]float64, len(numbers))
+	for i, n := range numbers {
+		v, err := n.Float64()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
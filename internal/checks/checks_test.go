@@ -0,0 +1,166 @@
+package checks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/icinga/check-netgear/internal/utils"
+	"github.com/icinga/check-netgear/netgear"
+)
+
+// output returns the plugin output of partial including the perfdata
+func output(partial *result.PartialResult) string {
+	overall := result.Overall{}
+	overall.AddSubcheck(*partial)
+	return overall.GetOutput()
+}
+
+func TestCheckTemperature(t *testing.T) {
+	global := Thresholds{Warn: utils.UpperBound(50), Crit: utils.UpperBound(60)}
+
+	tests := []struct {
+		name        string
+		sensor      netgear.SensorDetail
+		warnPercent float64
+		critPercent float64
+		overrides   Overrides
+		wantState   int
+		wantOutput  []string
+	}{
+		{
+			name:       "global thresholds",
+			sensor:     netgear.SensorDetail{Description: "System1", Temperature: netgear.NewNumber(44), MaxTemp: netgear.NewNumber(85)},
+			wantState:  check.OK,
+			wantOutput: []string{"[OK] System1: 44.0°C", "System1=44;~:50;~:60;0;85"},
+		},
+		{
+			name:        "warning percent of the maximum",
+			sensor:      netgear.SensorDetail{Description: "System1", Temperature: netgear.NewNumber(44), MaxTemp: netgear.NewNumber(80)},
+			warnPercent: 50,
+			wantState:   check.Warning,
+			wantOutput:  []string{"[WARNING] System1: 44.0°C", "System1=44;~:40;~:60;0;80"},
+		},
+		{
+			name:        "critical percent of the maximum",
+			sensor:      netgear.SensorDetail{Description: "System1", Temperature: netgear.NewNumber(44), MaxTemp: netgear.NewNumber(80)},
+			warnPercent: 40,
+			critPercent: 50,
+			wantState:   check.Critical,
+			wantOutput:  []string{"[CRITICAL] System1: 44.0°C", "System1=44;~:32;~:40;0;80"},
+		},
+		{
+			name:        "percent thresholds without maximum",
+			sensor:      netgear.SensorDetail{Description: "System1", Temperature: netgear.NewNumber(55)},
+			warnPercent: 10,
+			critPercent: 20,
+			wantState:   check.Warning,
+			wantOutput:  []string{"[WARNING] System1: 55.0°C", "System1=55;~:50;~:60;0"},
+		},
+		{
+			name:        "override takes precedence over percent thresholds",
+			sensor:      netgear.SensorDetail{Description: "System1", Temperature: netgear.NewNumber(44), MaxTemp: netgear.NewNumber(80)},
+			warnPercent: 40,
+			critPercent: 50,
+			overrides:   Overrides{"System1": {Warn: utils.UpperBound(70)}},
+			wantState:   check.Critical,
+			wantOutput:  []string{"System1=44;~:70;~:40;0;80"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := CheckTemperature([]netgear.SensorDetail{tt.sensor}, false, global, tt.warnPercent, tt.critPercent, tt.overrides)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			out := output(res)
+			if state := res.GetStatus(); state != tt.wantState {
+				t.Errorf("got state %d, want %d:\n%s", state, tt.wantState, out)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out, want) {
+					t.Errorf("output does not contain %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckFans(t *testing.T) {
+	global := Thresholds{Warn: utils.LowerBound(1000), Crit: utils.LowerBound(500)}
+
+	tests := []struct {
+		name       string
+		fan        netgear.FanDetail
+		overrides  Overrides
+		wantState  int
+		wantOutput []string
+	}{
+		{
+			name:       "operational",
+			fan:        netgear.FanDetail{Description: "FAN-1", Speed: netgear.NewNumber(2000), Status: "Operational"},
+			wantState:  check.OK,
+			wantOutput: []string{"[OK] FAN-1: 2000 RPM", "FAN-1=2000;1000:;500:;0"},
+		},
+		{
+			name:       "maximum speed",
+			fan:        netgear.FanDetail{Description: "FAN-1", Speed: netgear.NewNumber(2000), MaxSpeed: netgear.NewNumber(8000)},
+			wantState:  check.OK,
+			wantOutput: []string{"[OK] FAN-1: 2000/8000 RPM", "FAN-1=2000;1000:;500:;0;8000"},
+		},
+		{
+			name:       "too slow",
+			fan:        netgear.FanDetail{Description: "FAN-1", Speed: netgear.NewNumber(800)},
+			wantState:  check.Warning,
+			wantOutput: []string{"[WARNING] FAN-1: 800 RPM"},
+		},
+		{
+			name:       "failed while spinning",
+			fan:        netgear.FanDetail{Description: "FAN-1", Speed: netgear.NewNumber(2000), Status: "Failed"},
+			wantState:  check.Critical,
+			wantOutput: []string{"[CRITICAL] FAN-1: 2000 RPM (Failed)"},
+		},
+		{
+			name:       "not present",
+			fan:        netgear.FanDetail{Description: "FAN-2", Speed: netgear.NewNumber(0), Status: "Not Present"},
+			wantState:  check.OK,
+			wantOutput: []string{"[OK] FAN-2: not present"},
+		},
+		{
+			name:       "override of the lower bound",
+			fan:        netgear.FanDetail{Description: "FAN-1", Speed: netgear.NewNumber(800)},
+			overrides:  Overrides{"FAN-1": {Warn: utils.LowerBound(600)}},
+			wantState:  check.OK,
+			wantOutput: []string{"[OK] FAN-1: 800 RPM", "FAN-1=800;600:;500:;0"},
+		},
+		{
+			name:       "override doesn't allow a stopped fan",
+			fan:        netgear.FanDetail{Description: "FAN-1", Speed: netgear.NewNumber(0)},
+			overrides:  Overrides{"FAN-1": {Warn: utils.LowerBound(0), Crit: utils.LowerBound(0)}},
+			wantState:  check.Critical,
+			wantOutput: []string{"[CRITICAL] FAN-1: 0 RPM (stopped)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := CheckFans([]netgear.FanDetail{tt.fan}, false, global, tt.overrides)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			out := output(res)
+			if state := res.GetStatus(); state != tt.wantState {
+				t.Errorf("got state %d, want %d:\n%s", state, tt.wantState, out)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out, want) {
+					t.Errorf("output does not contain %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
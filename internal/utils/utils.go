@@ -2,17 +2,28 @@ package utils
 
 import "github.com/NETWAYS/go-check"
 
-func StatusByThreshold(value, warn, crit float64) int {
+// StatusByRange returns the state of value for thresholds in the Nagios range format, nil thresholds are ignored
+func StatusByRange(value float64, warn, crit *check.Threshold) int {
 	switch {
-	case value >= crit:
+	case crit != nil && crit.DoesViolate(value):
 		return check.Critical
-	case value >= warn:
+	case warn != nil && warn.DoesViolate(value):
 		return check.Warning
 	default:
 		return check.OK
 	}
 }
 
+// UpperBound returns a threshold that is violated by values above limit
+func UpperBound(limit float64) *check.Threshold {
+	return &check.Threshold{Lower: check.NegInf, Upper: limit}
+}
+
+// LowerBound returns a threshold that is violated by values below limit
+func LowerBound(limit float64) *check.Threshold {
+	return &check.Threshold{Lower: limit, Upper: check.PosInf}
+}
+
 func LossPercent(drop, total float64) float64 {
 	if total <= 0 {
 		return 0
@@ -1,20 +1,35 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/icinga/check-netgear/internal/checks"
+	"github.com/icinga/check-netgear/internal/utils"
 	"github.com/icinga/check-netgear/netgear"
 
 	"github.com/NETWAYS/go-check"
 	"github.com/NETWAYS/go-check/result"
 )
 
+// logoutTimeout limits the logout, which is performed even after the overall timeout was exceeded
+const logoutTimeout = 5 * time.Second
+
 // So that flag supports slices
 type stringSliceFlag []string
 
@@ -42,56 +57,298 @@ func (i *intSliceFlag) Set(v string) error {
 	return nil
 }
 
+// thresholdFlag is a threshold in the Nagios range format, e.g. "10:" to alert on values below 10. An empty value
+// disables the threshold.
+type thresholdFlag struct {
+	*check.Threshold
+}
+
+// thresholds returns t as warning and crit as critical threshold
+func (t thresholdFlag) thresholds(crit thresholdFlag) checks.Thresholds {
+	return checks.Thresholds{Warn: t.Threshold, Crit: crit.Threshold}
+}
+
+func (t *thresholdFlag) String() string {
+	if t.Threshold == nil {
+		return ""
+	}
+	return t.Threshold.String()
+}
+func (t *thresholdFlag) Set(v string) error {
+	if v == "" {
+		t.Threshold = nil
+		return nil
+	}
+	threshold, err := check.ParseThreshold(v)
+	if err != nil {
+		return err
+	}
+	t.Threshold = threshold
+	return nil
+}
+
+// upperBoundFlag sets a thresholdFlag for values that may be negative, like temperatures. Plain numbers are upper bounds
+// only, e.g. "50" is parsed as "~:50" instead of "0:50".
+type upperBoundFlag struct {
+	*thresholdFlag
+}
+
+func (f upperBoundFlag) String() string {
+	if f.thresholdFlag == nil {
+		return ""
+	}
+	return f.thresholdFlag.String()
+}
+func (f upperBoundFlag) Set(v string) error {
+	if limit, err := strconv.ParseFloat(v, 64); err == nil {
+		f.Threshold = utils.UpperBound(limit)
+		return nil
+	}
+	return f.thresholdFlag.Set(v)
+}
+
+// overrideFlag collects per item thresholds in the format ITEM=WARN:CRIT, e.g. "sensor-MAC=60:75", where the values
+// are upper bounds, or lower bounds if lowerBound is set. Ranges can be given as ITEM=WARN,CRIT, e.g.
+// "sensor-MAC=~:60,~:75". Either threshold may be empty to keep the global one.
+type overrideFlag struct {
+	overrides  checks.Overrides
+	lowerBound bool
+}
+
+func (o *overrideFlag) String() string {
+	items := make([]string, 0, len(o.overrides))
+	for _, item := range slices.Sorted(maps.Keys(o.overrides)) {
+		t := o.overrides[item]
+		items = append(items, fmt.Sprintf("%s=%s,%s", item, thresholdString(t.Warn), thresholdString(t.Crit)))
+	}
+	return strings.Join(items, " ")
+}
+func (o *overrideFlag) Set(v string) error {
+	item, spec, ok := strings.Cut(v, "=")
+	if !ok || item == "" {
+		return fmt.Errorf("expected ITEM=WARN:CRIT or ITEM=WARN,CRIT, got %q", v)
+	}
+	isRange := strings.Contains(spec, ",")
+	sep := ":"
+	if isRange {
+		sep = ","
+	}
+	warnSpec, critSpec, _ := strings.Cut(spec, sep)
+	if warnSpec == "" && critSpec == "" {
+		return fmt.Errorf("no threshold given for %q", item)
+	}
+
+	var thresholds checks.Thresholds
+	for _, t := range []struct {
+		spec      string
+		threshold **check.Threshold
+	}{{warnSpec, &thresholds.Warn}, {critSpec, &thresholds.Crit}} {
+		switch {
+		case t.spec == "":
+			continue
+		case isRange:
+			threshold, err := check.ParseThreshold(t.spec)
+			if err != nil {
+				return fmt.Errorf("invalid threshold %q for %q", t.spec, item)
+			}
+			*t.threshold = threshold
+		default:
+			limit, err := strconv.ParseFloat(t.spec, 64)
+			if err != nil {
+				return fmt.Errorf("invalid threshold %q for %q", t.spec, item)
+			}
+			if o.lowerBound {
+				*t.threshold = utils.LowerBound(limit)
+			} else {
+				*t.threshold = utils.UpperBound(limit)
+			}
+		}
+	}
+
+	if o.overrides == nil {
+		o.overrides = checks.Overrides{}
+	}
+	o.overrides[item] = thresholds
+	return nil
+}
+
+func thresholdString(t *check.Threshold) string {
+	if t == nil {
+		return ""
+	}
+	return t.String()
+}
+
 // Flags contains all command line flags that are relevant to check modes
 type Flags struct {
 	NoPerfdata bool
 
-	HideCpu  bool
-	HideMem  bool
-	HideTemp bool
-	HideFans bool
-
-	CpuWarn  float64
-	CpuCrit  float64
-	MemWarn  float64
-	MemCrit  float64
-	TempWarn float64
-	TempCrit float64
-	FanWarn  float64
-	FanCrit  float64
-	PortWarn float64
-	PortCrit float64
+	HideUptime bool
+	HideCpu    bool
+	HideMem    bool
+	HideTemp   bool
+	HideFans   bool
+
+	UptimeWarn thresholdFlag
+	UptimeCrit thresholdFlag
+	CpuWarn    thresholdFlag
+	CpuCrit    thresholdFlag
+	MemWarn    thresholdFlag
+	MemCrit    thresholdFlag
+	TempWarn   thresholdFlag
+	TempCrit   thresholdFlag
+	FanWarn    thresholdFlag
+	FanCrit    thresholdFlag
+	PortWarn   thresholdFlag
+	PortCrit   thresholdFlag
+
+	TempOverrides overrideFlag
+	FanOverrides  overrideFlag
+	PortOverrides overrideFlag
+
+	// TempWarnPercent and TempCritPercent are relative to the maximum temperature of each sensor, 0 disables them
+	TempWarnPercent float64
+	TempCritPercent float64
 
 	PortsToCheck intSliceFlag
+
+	// Units selects the members of a stack to check, HiddenUnits excludes members
+	Units       intSliceFlag
+	HiddenUnits intSliceFlag
+}
+
+// register defines the command line flags of the check modes on fs and sets their defaults
+func (flags *Flags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&flags.NoPerfdata, "noperfdata", false, "Do not output performance data")
+
+	fs.BoolVar(&flags.HideUptime, "nouptime", false, "Hide the uptime info")
+	fs.BoolVar(&flags.HideCpu, "nocpu", false, "Hide the CPU info")
+	fs.BoolVar(&flags.HideMem, "nomem", false, "Hide the RAM info")
+	fs.BoolVar(&flags.HideTemp, "notemp", false, "Hide the Temperature info")
+	fs.BoolVar(&flags.HideFans, "nofans", false, "Hide the Fans info")
+	fs.Var(&flags.Units, "unit", "Stack units to check (repeatable) (default: all)")
+	fs.Var(&flags.HiddenUnits, "nounit", "Hide the info of a stack unit (repeatable)")
+
+	// Thresholds
+	// all thresholds use the Nagios range format, plain numbers N alert on values outside of 0:N
+	fs.Var(&flags.UptimeWarn, "uptime-warning", "Uptime warning range in seconds, e.g. 3600: to detect reboots within the last hour")
+	fs.Var(&flags.UptimeCrit, "uptime-critical", "Uptime critical range in seconds, e.g. 600:")
+	flags.CpuWarn = thresholdFlag{&check.Threshold{Upper: 50}}
+	flags.CpuCrit = thresholdFlag{&check.Threshold{Upper: 90}}
+	fs.Var(&flags.CpuWarn, "cpu-warning", "CPU usage warning range in percent")
+	fs.Var(&flags.CpuCrit, "cpu-critical", "CPU usage critical range in percent")
+	flags.MemWarn = thresholdFlag{&check.Threshold{Upper: 50}}
+	flags.MemCrit = thresholdFlag{&check.Threshold{Upper: 90}}
+	fs.Var(&flags.MemWarn, "mem-warning", "RAM usage warning range in percent")
+	fs.Var(&flags.MemCrit, "mem-critical", "RAM usage critical range in percent")
+	flags.FanWarn = thresholdFlag{&check.Threshold{Lower: 1000, Upper: check.PosInf}}
+	fs.Var(&flags.FanWarn, "fan-warning", "Fan speed warning range in RPM, e.g. 1000: or 1000:8000 (stopped fans are always critical)")
+	fs.Var(&flags.FanCrit, "fan-critical", "Fan speed critical range in RPM, e.g. 500:")
+	// temperatures may be below zero, so plain numbers N are read as ~:N
+	flags.TempWarn = thresholdFlag{&check.Threshold{Lower: check.NegInf, Upper: 50}}
+	flags.TempCrit = thresholdFlag{&check.Threshold{Lower: check.NegInf, Upper: 70}}
+	fs.Var(upperBoundFlag{&flags.TempWarn}, "temp-warning", "Temperature warning range in °C, plain numbers alert on values above them")
+	fs.Var(upperBoundFlag{&flags.TempCrit}, "temp-critical", "Temperature critical range in °C, plain numbers alert on values above them")
+	fs.Float64Var(&flags.TempWarnPercent, "temp-warning-percent", 0, "Temperature warning threshold in percent of the maximum temperature of each sensor, e.g. 85 (overrides -temp-warning)")
+	fs.Float64Var(&flags.TempCritPercent, "temp-critical-percent", 0, "Temperature critical threshold in percent of the maximum temperature of each sensor, e.g. 100 (overrides -temp-critical)")
+	flags.PortWarn = thresholdFlag{&check.Threshold{Upper: 5}}
+	flags.PortCrit = thresholdFlag{&check.Threshold{Upper: 20}}
+	fs.Var(&flags.PortWarn, "stats-warning", "Port packet loss warning range in percent")
+	fs.Var(&flags.PortCrit, "stats-critical", "Port packet loss critical range in percent")
+
+	flags.FanOverrides.lowerBound = true
+	fs.Var(&flags.TempOverrides, "temp-threshold", "Thresholds of a single sensor as SENSOR=WARN:CRIT or SENSOR=WARN_RANGE,CRIT_RANGE, e.g. sensor-MAC=60:75 (repeatable)")
+	fs.Var(&flags.FanOverrides, "fan-threshold", "Minimum speeds of a single fan as FAN=WARN:CRIT or FAN=WARN_RANGE,CRIT_RANGE, e.g. FAN-2=1500: (repeatable)")
+	fs.Var(&flags.PortOverrides, "port-threshold", "Loss thresholds of a single port as PORT=WARN:CRIT or PORT=WARN_RANGE,CRIT_RANGE, e.g. 24=1:5 (repeatable)")
+
+	flags.PortsToCheck = intSliceFlag{1, 2, 3, 4, 5, 6, 7, 8}
+	fs.Var(&flags.PortsToCheck, "port", "Ports to check (repeatable)")
 }
 
 // ModeBasic contains all the basic hardware information of the switch, including CPU and RAM usage, temperature and fan
-// speed
-func ModeBasic(netgearSession *netgear.Netgear, flags *Flags) (*result.PartialResult, error) {
-	deviceInfo, err := netgearSession.DeviceInfo()
+// speed. Every member of a stack is reported as its own subcheck, with its number prefixed to the perfdata labels.
+func ModeBasic(ctx context.Context, netgearSession *netgear.Netgear, flags *Flags) (*result.PartialResult, error) {
+	deviceInfo, err := netgearSession.DeviceInfo(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error retrieving device info: %w", err)
+		return nil, fmt.Errorf("error retrieving device info: %s", errorMessage(err))
 	}
 
-	if len(deviceInfo.DeviceInfo.Details) == 0 {
+	units := len(deviceInfo.DeviceInfo.Details)
+	if units == 0 {
 		return nil, fmt.Errorf("error retrieving device info")
 	}
-	upTime := deviceInfo.DeviceInfo.Details[0].Uptime
+	for _, unit := range slices.Concat(flags.Units, flags.HiddenUnits) {
+		if unit < 1 || unit > units {
+			return nil, fmt.Errorf("unit %d not found, the device has %d units", unit, units)
+		}
+	}
+
+	// single switches are reported like before, so the perfdata labels don't change
+	if units == 1 {
+		return checkUnit(deviceInfo, 0, "this device", flags)
+	}
+
+	o := result.PartialResult{
+		Output: fmt.Sprintf("Stack of %d units", units),
+	}
+	for i := range units {
+		unit := i + 1
+		if len(flags.Units) > 0 && !slices.Contains(flags.Units, unit) || slices.Contains(flags.HiddenUnits, unit) {
+			continue
+		}
+
+		unitPartial, err := checkUnit(deviceInfo, i, fmt.Sprintf("unit %d", unit), flags)
+		if err != nil {
+			return nil, err
+		}
+		unitPartial.Output = fmt.Sprintf("Unit %d: %s", unit, unitPartial.Output)
+		prefixPerfdata(unitPartial, fmt.Sprintf("unit %d ", unit))
+		o.AddSubcheck(*unitPartial)
+	}
+
+	if len(o.PartialResults) == 0 {
+		return nil, fmt.Errorf("all units of the stack are hidden")
+	}
+	return &o, nil
+}
+
+// checkUnit checks the unit at index i of the device info. name is used in error messages.
+func checkUnit(deviceInfo *netgear.DeviceInfo, i int, name string, flags *Flags) (*result.PartialResult, error) {
+	info := deviceInfo.DeviceInfo
+	upTime := info.Details[i].Uptime
 
 	o := result.PartialResult{
 		Output: fmt.Sprintf("Device Info: Uptime - %v", upTime),
 	}
 
-	if !flags.HideCpu {
-		if len(deviceInfo.DeviceInfo.Cpu) == 0 {
-			return nil, fmt.Errorf("no CPU info for this device")
+	if !flags.HideUptime {
+		var uptimePartial *result.PartialResult
+		uptime, err := netgear.ParseUptime(upTime)
+		if err == nil {
+			uptimePartial, err = checks.CheckUptime(uptime, flags.NoPerfdata, flags.UptimeWarn.thresholds(flags.UptimeCrit))
 		}
-		cpuUsage, err := netgear.StringPercentToFloat(deviceInfo.DeviceInfo.Cpu[0].Usage)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing CPU usage: %w", err)
+			errRes := result.NewPartialResult()
+			errRes.Output = fmt.Sprintf("Uptime check error: %v", err)
+			err := errRes.SetState(check.Unknown)
+			if err != nil {
+				return nil, err
+			}
+			o.AddSubcheck(errRes)
+		} else {
+			o.AddSubcheck(*uptimePartial)
 		}
+	}
 
-		cpuPartial, err := checks.CheckCPU(cpuUsage, flags.NoPerfdata, flags.CpuWarn, flags.CpuCrit)
+	if !flags.HideCpu {
+		if len(info.Cpu) <= i {
+			return nil, fmt.Errorf("no CPU info for %s", name)
+		}
+		var cpuPartial *result.PartialResult
+		cpuUsage, err := info.Cpu[i].Usage.Float64()
+		if err == nil {
+			cpuPartial, err = checks.CheckCPU(cpuUsage, flags.NoPerfdata, flags.CpuWarn.thresholds(flags.CpuCrit))
+		}
 		if err != nil {
 			errRes := result.NewPartialResult()
 			errRes.Output = fmt.Sprintf("CPU check error: %v", err)
@@ -106,15 +363,14 @@ func ModeBasic(netgearSession *netgear.Netgear, flags *Flags) (*result.PartialRe
 	}
 
 	if !flags.HideMem {
-		if len(deviceInfo.DeviceInfo.Memory) == 0 {
-			return nil, fmt.Errorf("no Memory info for this device")
+		if len(info.Memory) <= i {
+			return nil, fmt.Errorf("no Memory info for %s", name)
 		}
-		memUsage, err := netgear.StringPercentToFloat(deviceInfo.DeviceInfo.Memory[0].Usage)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing Memory usage: %w", err)
+		var memPartial *result.PartialResult
+		memUsage, err := info.Memory[i].Usage.Float64()
+		if err == nil {
+			memPartial, err = checks.CheckMemory(memUsage, flags.NoPerfdata, flags.MemWarn.thresholds(flags.MemCrit))
 		}
-
-		memPartial, err := checks.CheckMemory(memUsage, flags.NoPerfdata, flags.MemWarn, flags.MemCrit)
 		if err != nil {
 			errRes := result.NewPartialResult()
 			errRes.Output = fmt.Sprintf("Memory check error: %v", err)
@@ -129,11 +385,12 @@ func ModeBasic(netgearSession *netgear.Netgear, flags *Flags) (*result.PartialRe
 	}
 
 	if !flags.HideTemp {
-		if len(deviceInfo.DeviceInfo.Sensor) == 0 {
-			return nil, fmt.Errorf("no Temperature info for this device")
+		if len(info.Sensor) <= i {
+			return nil, fmt.Errorf("no Temperature info for %s", name)
 		}
-		sensorDetails := deviceInfo.DeviceInfo.Sensor[0].Details
-		tempPartial, err := checks.CheckTemperature(sensorDetails, flags.NoPerfdata, flags.TempWarn, flags.TempCrit)
+		sensorDetails := info.Sensor[i].Details
+		tempPartial, err := checks.CheckTemperature(sensorDetails, flags.NoPerfdata, flags.TempWarn.thresholds(flags.TempCrit),
+			flags.TempWarnPercent, flags.TempCritPercent, flags.TempOverrides.overrides)
 		if err != nil {
 			errRes := result.NewPartialResult()
 			errRes.Output = fmt.Sprintf("Temperature check error: %v", err)
@@ -148,14 +405,22 @@ func ModeBasic(netgearSession *netgear.Netgear, flags *Flags) (*result.PartialRe
 	}
 
 	if !flags.HideFans {
-		if len(deviceInfo.DeviceInfo.Fan) == 0 {
-			return nil, fmt.Errorf("no Fan info for this device")
+		if len(info.Fan) <= i {
+			return nil, fmt.Errorf("no Fan info for %s", name)
+		}
+		fans := info.Fan[i].Details
+		if len(info.Details) == 1 {
+			// a single switch may report several fan trays
+			fans = nil
+			for _, tray := range info.Fan {
+				fans = append(fans, tray.Details...)
+			}
 		}
-		if len(deviceInfo.DeviceInfo.Fan[0].Details) == 0 {
-			return nil, fmt.Errorf("no Fan details for this device")
+		if len(fans) == 0 {
+			return nil, fmt.Errorf("no Fan details for %s", name)
 		}
-		fan := deviceInfo.DeviceInfo.Fan[0].Details[0]
-		fanPartial, err := checks.CheckFans(flags.NoPerfdata, fan.Description, fan.Speed, flags.FanWarn, flags.FanCrit)
+		fanPartial, err := checks.CheckFans(fans, flags.NoPerfdata, flags.FanWarn.thresholds(flags.FanCrit),
+			flags.FanOverrides.overrides)
 		if err != nil {
 			errRes := result.NewPartialResult()
 			errRes.Output = fmt.Sprintf("Fans check error: %v", err)
@@ -172,13 +437,31 @@ func ModeBasic(netgearSession *netgear.Netgear, flags *Flags) (*result.PartialRe
 	return &o, nil
 }
 
+// prefixPerfdata prepends prefix to the perfdata labels of partial and all its subchecks
+func prefixPerfdata(partial *result.PartialResult, prefix string) {
+	for _, p := range partial.Perfdata {
+		p.Label = prefix + p.Label
+	}
+	for i := range partial.PartialResults {
+		prefixPerfdata(&partial.PartialResults[i], prefix)
+	}
+}
+
 // ModePorts monitors the network traffic on the ports and reports back the percentage of dropped packets
-func ModePorts(netgearSession *netgear.Netgear, flags *Flags) (*result.PartialResult, error) {
+func ModePorts(ctx context.Context, netgearSession *netgear.Netgear, flags *Flags) (*result.PartialResult, error) {
 	o := result.PartialResult{}
-	portsIn, err := netgearSession.PortStatistics("inbound")
-	if err != nil {
+
+	// inbound and outbound statistics are independent requests, so they are fetched in parallel
+	var portsIn, portsOut *netgear.PortStatistics
+	var errIn, errOut error
+	var wg sync.WaitGroup
+	wg.Go(func() { portsIn, errIn = netgearSession.PortStatistics(ctx, "inbound", flags.PortsToCheck) })
+	wg.Go(func() { portsOut, errOut = netgearSession.PortStatistics(ctx, "outbound", flags.PortsToCheck) })
+	wg.Wait()
+
+	if errIn != nil {
 		errRes := result.NewPartialResult()
-		errRes.Output = fmt.Sprintf("Inbound port check error: %v", err)
+		errRes.Output = fmt.Sprintf("Inbound port check error: %v", errorMessage(errIn))
 		err := errRes.SetState(check.Unknown)
 		if err != nil {
 			return nil, err
@@ -186,10 +469,9 @@ func ModePorts(netgearSession *netgear.Netgear, flags *Flags) (*result.PartialRe
 		o.AddSubcheck(errRes)
 		return &o, nil
 	}
-	portsOut, err := netgearSession.PortStatistics("outbound")
-	if err != nil {
+	if errOut != nil {
 		errRes := result.NewPartialResult()
-		errRes.Output = fmt.Sprintf("Outbound port check error: %v", err)
+		errRes.Output = fmt.Sprintf("Outbound port check error: %v", errorMessage(errOut))
 		err := errRes.SetState(check.Unknown)
 		if err != nil {
 			return nil, err
@@ -201,7 +483,8 @@ func ModePorts(netgearSession *netgear.Netgear, flags *Flags) (*result.PartialRe
 	inRows := portsIn.PortStatistics.Rows
 	outRows := portsOut.PortStatistics.Rows
 
-	portsPartial, err := checks.CheckPorts(inRows, outRows, flags.PortsToCheck, flags.NoPerfdata, flags.PortWarn, flags.PortCrit)
+	portsPartial, err := checks.CheckPorts(inRows, outRows, flags.PortsToCheck, flags.NoPerfdata,
+		flags.PortWarn.thresholds(flags.PortCrit), flags.PortOverrides.overrides)
 	if err != nil {
 		errRes := result.NewPartialResult()
 		errRes.Output = fmt.Sprintf("Ports check error: %v", err)
@@ -218,13 +501,25 @@ func ModePorts(netgearSession *netgear.Netgear, flags *Flags) (*result.PartialRe
 	return &o, nil
 }
 
-// ModePoE checks the ports PoE state
-func ModePoE(netgearSession *netgear.Netgear, flags *Flags) (*result.PartialResult, error) {
+// ModePoE checks the ports PoE state. Models without PoE are skipped.
+func ModePoE(ctx context.Context, netgearSession *netgear.Netgear, flags *Flags) (*result.PartialResult, error) {
 	o := result.PartialResult{}
-	poeStatus, err := netgearSession.PoeStatus()
+
+	// if the capabilities can't be read, PoE is checked anyway and an unsupported endpoint is detected below
+	capabilities, err := netgearSession.Capabilities(ctx)
+	if err != nil {
+		capabilities = &netgear.Capabilities{}
+	} else if !capabilities.PoE {
+		return notSupported("PoE", capabilities)
+	}
+
+	poeStatus, err := netgearSession.PoeStatus(ctx)
+	if errors.Is(err, netgear.ErrNotFound) {
+		return notSupported("PoE", capabilities)
+	}
 	if err != nil {
 		errRes := result.NewPartialResult()
-		errRes.Output = fmt.Sprintf("PoE check error: %v", err)
+		errRes.Output = fmt.Sprintf("PoE check error: %v", errorMessage(err))
 		err := errRes.SetState(check.Unknown)
 		if err != nil {
 			return nil, err
@@ -250,126 +545,298 @@ func ModePoE(netgearSession *netgear.Netgear, flags *Flags) (*result.PartialResu
 	return &o, nil
 }
 
+// notSupported creates an OK result for a feature that is skipped, because the device does not support it
+func notSupported(feature string, capabilities *netgear.Capabilities) (*result.PartialResult, error) {
+	o := result.NewPartialResult()
+	o.Output = fmt.Sprintf("%s not supported on %v", feature, capabilities)
+	if err := o.SetState(check.OK); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// mode is a check mode that can be selected using --mode
+type mode struct {
+	name string
+	run  func(ctx context.Context, netgearSession *netgear.Netgear, flags *Flags) (*result.PartialResult, error)
+}
+
+// modes lists all check modes in the order their results are printed
+var modes = []mode{
+	{"basic", ModeBasic},
+	{"ports", ModePorts},
+	{"poe", ModePoE},
+}
+
+// errorMessage turns errors returned by the netgear client into a readable reason for the UNKNOWN state
+func errorMessage(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout exceeded, consider raising --timeout"
+	case errors.Is(err, context.Canceled):
+		return "interrupted by signal"
+	case errors.Is(err, netgear.ErrLoginFailed):
+		return "invalid username or password"
+	case errors.Is(err, netgear.ErrUnauthorized):
+		return fmt.Sprintf("not authorized, the session is invalid or expired: %v", err)
+	case errors.Is(err, netgear.ErrForbidden):
+		return fmt.Sprintf("permission denied, check the privileges of the user: %v", err)
+	case errors.Is(err, netgear.ErrNotFound):
+		return fmt.Sprintf("endpoint not available on this device: %v", err)
+	case errors.Is(err, netgear.ErrRateLimited):
+		return fmt.Sprintf("rate limited by the device: %v", err)
+	default:
+		return err.Error()
+	}
+}
+
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the plugin with the command line arguments args and returns the exit code, so deferred cleanups like
+// the logout are run on every path. The check result is written to stdout, usage, statistics and traces to stderr.
+func run(args []string, stdout, stderr io.Writer) int {
 	flags := Flags{}
 
-	flag.BoolVar(&flags.NoPerfdata, "noperfdata", false, "Do not output performance data")
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	fs.SetOutput(stderr)
 
-	flag.BoolVar(&flags.HideCpu, "nocpu", false, "Hide the CPU info")
-	flag.BoolVar(&flags.HideMem, "nomem", false, "Hide the RAM info")
-	flag.BoolVar(&flags.HideTemp, "notemp", false, "Hide the Temperature info")
-	flag.BoolVar(&flags.HideFans, "nofans", false, "Hide the Fans info")
+	flags.register(fs)
 
-	mode := stringSliceFlag{}
-	flag.Var(&mode, "mode", "Output modes to enable {basic|ports|poe|all} (repeatable) (default: basic)")
+	modeNames := stringSliceFlag{}
+	fs.Var(&modeNames, "mode", "Output modes to enable {basic|ports|poe|all} (repeatable) (default: basic)")
 
-	baseURL := flag.String("base-url", "http://192.168.0.239", "Base URL to use")
+	hostname := fs.String("hostname", "", "Hostname, IP address or URL of the switch, e.g. 192.0.2.10, [2001:db8::1]:8443 or https://proxy/switches/foo")
+	fs.StringVar(hostname, "base-url", "", "Alias of --hostname")
 
-	username := flag.String("username", "", "Username for authentication")
-	passwordFlag := flag.String("password", "", "Password for authentication")
-	passFile := flag.String("password-file", "", "Path to the file containing the password")
+	username := fs.String("username", "", "Username for authentication")
+	passwordFlag := fs.String("password", "", "Password for authentication")
+	passFile := fs.String("password-file", "", "Path to the file containing the password")
 
-	// Thresholds
-	flag.Float64Var(&flags.CpuWarn, "cpu-warning", 50, "CPU usage warning threshold")
-	flag.Float64Var(&flags.CpuCrit, "cpu-critical", 90, "CPU usage critical threshold")
-	flag.Float64Var(&flags.MemWarn, "mem-warning", 50, "RAM usage warning threshold")
-	flag.Float64Var(&flags.MemCrit, "mem-critical", 90, "RAM usage critical threshold")
-	flag.Float64Var(&flags.FanWarn, "fan-warning", 3000, "Fan speed warning threshold")
-	flag.Float64Var(&flags.FanCrit, "fan-critical", 5000, "Fan speed critical threshold")
-	flag.Float64Var(&flags.TempWarn, "temp-warning", 50, "Temperature warning threshold")
-	flag.Float64Var(&flags.TempCrit, "temp-critical", 70, "Temperature critical threshold")
-	flag.Float64Var(&flags.PortWarn, "stats-warning", 5, "Port stats warning threshold")
-	flag.Float64Var(&flags.PortCrit, "stats-critical", 20, "Port stats critical threshold")
+	tlsOpts := netgear.TLSOptions{}
+	fs.StringVar(&tlsOpts.CAFile, "ca-file", "", "Path to a CA bundle used to verify the switch certificate")
+	fs.StringVar(&tlsOpts.ClientCert, "client-cert", "", "Path to a client certificate for TLS authentication")
+	fs.StringVar(&tlsOpts.ClientKey, "client-key", "", "Path to the key of the client certificate")
+	fs.BoolVar(&tlsOpts.InsecureSkipVerify, "insecure-skip-verify", false, "Do not verify the switch certificate")
+	fs.StringVar(&tlsOpts.MinVersion, "tls-min-version", "", "Minimum TLS version {1.0|1.1|1.2|1.3} (default: 1.2)")
+	fs.StringVar(&tlsOpts.ServerName, "tls-server-name", "", "Server name used to verify the switch certificate")
 
-	flags.PortsToCheck = intSliceFlag{1, 2, 3, 4, 5, 6, 7, 8}
-	flag.Var(&flags.PortsToCheck, "port", "Ports to check (repeatable)")
+	proxy := fs.String("proxy", "", "Proxy to connect through {http|https|socks5|socks5h}://[user:password@]host:port (default: from HTTP_PROXY/HTTPS_PROXY)")
+
+	sessionCacheDir := fs.String("session-cache", "", "Directory to cache session tokens in across runs (default: disabled)")
+	sessionCacheTTL := fs.Duration("session-cache-ttl", 5*time.Minute, "Time after which a cached session token is no longer used, extended by every run reusing it")
+
+	retries := fs.Int("retries", 0, "Number of retries for failed requests caused by transient errors")
+	retryWait := fs.Duration("retry-wait", 500*time.Millisecond, "Base delay between retries, doubled for every retry")
+
+	maxParallel := fs.Int("max-parallel", 4, "Maximum number of parallel requests to the switch")
+
+	debug := fs.Bool("debug", false, "Log all API requests and responses to stderr, credentials are redacted")
+	traceFile := fs.String("trace-file", "", "Log all API requests and responses to this file instead of stderr")
+
+	recordDir := fs.String("record", "", "Save anonymized API responses to this directory, e.g. for bug reports")
+	replayDir := fs.String("replay", "", "Use the API responses saved by --record in this directory instead of a switch")
+	fs.StringVar(replayDir, "input-dir", "", "Evaluate the checks offline using the JSON responses saved in this directory")
+
+	verbose := fs.Bool("verbose", false, "Print statistics about the API requests to stderr")
 
-	help := flag.Bool("help", false, "Show this help")
-	flag.BoolVar(help, "h", false, "Show this help")
+	timeout := fs.Duration("timeout", 30*time.Second, "Overall timeout for the check, the logout may take up to 5s longer")
 
-	flag.Parse()
+	help := fs.Bool("help", false, "Show this help")
+	fs.BoolVar(help, "h", false, "Show this help")
+
+	if err := fs.Parse(args); err != nil {
+		// the error and the usage were printed by the flag set, invalid arguments are reported as UNKNOWN
+		return check.Unknown
+	}
 
 	if *help {
-		flag.Usage()
-		return
+		fs.Usage()
+		return check.OK
 	}
 
 	var password string
 	if *passFile != "" {
 		content, err := os.ReadFile(*passFile)
 		if err != nil {
-			fmt.Printf("Error reading password file: %v\n", err)
-			os.Exit(check.Unknown)
+			fmt.Fprintf(stdout, "Error reading password file: %v\n", err)
+			return check.Unknown
 		}
 		password = strings.TrimSpace(string(content))
 	} else {
 		password = *passwordFlag
 	}
 
+	if *replayDir != "" {
+		// replayed sessions don't need credentials and must not replace a cached session of a switch
+		*username, password = "replay", "replay"
+		*sessionCacheDir = ""
+		if *hostname == "" {
+			*hostname = "replay"
+		}
+	}
+
+	if *hostname == "" {
+		fmt.Fprintln(stdout, "A hostname (via -hostname) is required")
+		return check.Unknown
+	}
+
 	if *username == "" || password == "" {
-		fmt.Println("Username and a password (via -password or -password-file) are required")
-		os.Exit(check.Unknown)
+		fmt.Fprintln(stdout, "Username and a password (via -password or -password-file) are required")
+		return check.Unknown
 	}
 
-	netgearSession, err := netgear.NewNetgear(*baseURL, *username, password)
+	// SIGINT and SIGTERM abort all requests, so the session is closed before exiting
+	signalCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(signalCtx, *timeout)
+	defer cancel()
+
+	tlsConfig, err := netgear.NewTLSConfig(tlsOpts)
 	if err != nil {
-		fmt.Printf("URL error: %v", err)
-		os.Exit(check.Unknown)
+		fmt.Fprintf(stdout, "TLS error: %v\n", err)
+		return check.Unknown
 	}
-	if err := netgearSession.Login(); err != nil {
-		fmt.Printf("Error while trying to login: %v\n", err)
-		os.Exit(check.Unknown)
+
+	retryPolicy := netgear.DefaultRetryPolicy()
+	retryPolicy.MaxAttempts = *retries + 1
+	retryPolicy.Wait = *retryWait
+
+	opts := []netgear.Option{
+		netgear.WithTLSConfig(tlsConfig),
+		netgear.WithRetryPolicy(retryPolicy),
+		netgear.WithMaxParallel(*maxParallel),
 	}
-	defer func() { _ = netgearSession.Logout() }()
 
-	if len(mode) == 0 {
-		mode = append(mode, "basic")
-	} else if slices.Contains(mode, "all") {
-		mode = append(mode, "basic", "ports", "poe")
+	if *proxy != "" {
+		proxyURL, err := url.Parse(*proxy)
+		if err != nil {
+			fmt.Fprintf(stdout, "Invalid proxy URL: %v\n", err)
+			return check.Unknown
+		}
+		opts = append(opts, netgear.WithProxy(proxyURL))
 	}
 
-	worstStatus := check.OK
-	o := result.Overall{}
+	if *recordDir != "" {
+		opts = append(opts, netgear.WithRecorder(*recordDir))
+	}
+	if *replayDir != "" {
+		opts = append(opts, netgear.WithReplay(*replayDir))
+	}
 
-	// Basic check
-	if slices.Contains(mode, "basic") {
-		subcheck, err := ModeBasic(netgearSession, &flags)
+	var trace io.Writer
+	if *traceFile != "" {
+		f, err := os.OpenFile(*traceFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
 		if err != nil {
-			fmt.Print(err)
-			os.Exit(check.Unknown)
+			fmt.Fprintf(stdout, "Error opening trace file: %v\n", err)
+			return check.Unknown
 		}
-		o.AddSubcheck(*subcheck)
-		worstStatus = result.WorstState(worstStatus, subcheck.GetStatus())
+		defer func() { _ = f.Close() }()
+		trace = f
+	} else if *debug {
+		trace = stderr
+	}
+	if trace != nil {
+		logger := slog.New(slog.NewTextHandler(trace, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		opts = append(opts, netgear.WithTrace(trace), netgear.WithLogger(logger))
+	} else if *recordDir != "" {
+		// responses that could not be recorded are reported, the check itself continues
+		logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+		opts = append(opts, netgear.WithLogger(logger))
 	}
 
-	// ports
-	if slices.Contains(mode, "ports") {
-		subcheck, err := ModePorts(netgearSession, &flags)
+	if *sessionCacheDir != "" {
+		cache, err := netgear.NewSessionCache(*sessionCacheDir, *sessionCacheTTL)
 		if err != nil {
-			fmt.Print(err)
-			os.Exit(check.Unknown)
+			fmt.Fprintf(stdout, "Session cache error: %v\n", err)
+			return check.Unknown
 		}
-		o.AddSubcheck(*subcheck)
-		worstStatus = result.WorstState(worstStatus, subcheck.GetStatus())
+		opts = append(opts, netgear.WithSessionCache(cache))
 	}
 
-	// poe stuff
-	if slices.Contains(mode, "poe") {
-		subcheck, err := ModePoE(netgearSession, &flags)
-		if err != nil {
-			fmt.Print(err)
-			os.Exit(check.Unknown)
+	netgearSession, err := netgear.NewNetgear(*hostname, *username, password, opts...)
+	if err != nil {
+		fmt.Fprintf(stdout, "Error creating client: %v\n", err)
+		return check.Unknown
+	}
+	if *verbose {
+		// deferred first, so the statistics are printed on every exit path after the other cleanups
+		defer func() {
+			requests, attempts := netgearSession.Stats()
+			fmt.Fprintf(stderr, "%d API requests, %d attempts\n", requests, attempts)
+		}()
+	}
+
+	if err := netgearSession.Authenticate(ctx); err != nil {
+		fmt.Fprintf(stdout, "Error while trying to login: %v\n", errorMessage(err))
+		return check.Unknown
+	}
+	// cached sessions are kept open for the next run
+	if *sessionCacheDir == "" {
+		defer func() {
+			// the logout has to work even if the check timed out or was interrupted
+			logoutCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), logoutTimeout)
+			defer cancel()
+			if err := netgearSession.Logout(logoutCtx); err != nil && *verbose {
+				fmt.Fprintln(stderr, err)
+			}
+		}()
+	}
+
+	if len(modeNames) == 0 {
+		modeNames = append(modeNames, "basic")
+	} else if slices.Contains(modeNames, "all") {
+		modeNames = append(modeNames, "basic", "ports", "poe")
+	}
+
+	// the modes run in parallel, the number of concurrent requests is limited by the client
+	var selected []mode
+	for _, m := range modes {
+		if slices.Contains(modeNames, m.name) {
+			selected = append(selected, m)
+		}
+	}
+
+	subchecks := make([]*result.PartialResult, len(selected))
+	errs := make([]error, len(selected))
+	var wg sync.WaitGroup
+	for i, m := range selected {
+		wg.Go(func() {
+			subchecks[i], errs[i] = m.run(ctx, netgearSession, &flags)
+		})
+	}
+	wg.Wait()
+
+	worstStatus := check.OK
+	o := result.Overall{}
+
+	// results are added in a fixed order, regardless of which mode finished first
+	for i := range selected {
+		if errs[i] != nil {
+			fmt.Fprint(stdout, errorMessage(errs[i]))
+			return check.Unknown
 		}
-		o.AddSubcheck(*subcheck)
-		worstStatus = result.WorstState(worstStatus, subcheck.GetStatus())
+		o.AddSubcheck(*subchecks[i])
+		worstStatus = result.WorstState(worstStatus, subchecks[i].GetStatus())
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		fmt.Fprintf(stdout, "Timeout after %v while checking the device\n", *timeout)
+		return check.Unknown
+	}
+	if signalCtx.Err() != nil {
+		fmt.Fprintln(stdout, "Interrupted by signal")
+		return check.Unknown
 	}
 
 	if len(o.PartialResults) == 0 {
-		fmt.Print("No valid modes selected")
-		os.Exit(check.Unknown)
+		fmt.Fprint(stdout, "No valid modes selected")
+		return check.Unknown
 	}
 
-	fmt.Print(o.GetOutput())
+	fmt.Fprint(stdout, o.GetOutput())
 
-	os.Exit(worstStatus)
+	return worstStatus
 }
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/icinga/check-netgear/netgear"
+	"github.com/icinga/check-netgear/netgear/netgeartest"
+)
+
+// parseFlags returns the flags of the check modes with their defaults and the given command line arguments applied
+func parseFlags(t *testing.T, args ...string) *Flags {
+	t.Helper()
+
+	var flags Flags
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("invalid flags %v: %v", args, err)
+	}
+	return &flags
+}
+
+// loggedInClient returns a client logged in to srv
+func loggedInClient(t *testing.T, srv *netgeartest.Server) *netgear.Netgear {
+	t.Helper()
+
+	n, err := netgear.NewNetgear(srv.URL, srv.Username, srv.Password)
+	if err != nil {
+		t.Fatalf("NewNetgear: %v", err)
+	}
+	if err := n.Login(context.Background()); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	return n
+}
+
+func TestModes(t *testing.T) {
+	hotSensor := netgeartest.DefaultDeviceInfo()
+	hotSensor.DeviceInfo.Sensor[0].Details[0].Temperature = netgear.NewNumber(60)
+
+	stoppedFan := netgeartest.DefaultDeviceInfo()
+	stoppedFan.DeviceInfo.Fan[0].Details[0].Speed = netgear.NewNumber(0)
+
+	stack := netgeartest.DefaultDeviceInfo()
+	stack.DeviceInfo.Details = append(stack.DeviceInfo.Details, stack.DeviceInfo.Details[0])
+	stack.DeviceInfo.Fan = append(stack.DeviceInfo.Fan, stack.DeviceInfo.Fan[0])
+	stack.DeviceInfo.Sensor = append(stack.DeviceInfo.Sensor, stack.DeviceInfo.Sensor[0])
+	stack.DeviceInfo.Cpu = append(stack.DeviceInfo.Cpu, stack.DeviceInfo.Cpu[0])
+	stack.DeviceInfo.Memory = append(stack.DeviceInfo.Memory, stack.DeviceInfo.Memory[0])
+
+	rebooted := netgeartest.DefaultDeviceInfo()
+	rebooted.DeviceInfo.Details[0].Uptime = "0 days, 0 hrs, 10 mins, 0 secs"
+
+	lossyPorts := netgeartest.PortRows(8)
+	lossyPorts[2].InDropPkts = netgear.NewNumber(100)
+
+	nonPoEModel := netgeartest.DefaultDeviceInfo()
+	nonPoEModel.DeviceInfo.Details[0].Model = "M4250-16XF"
+
+	// a PoE model without PoE in its name
+	otherPoEModel := netgeartest.DefaultDeviceInfo()
+	otherPoEModel.DeviceInfo.Details[0].Model = "GSM4248P"
+
+	tests := []struct {
+		name       string
+		mode       func(context.Context, *netgear.Netgear, *Flags) (*result.PartialResult, error)
+		setup      func(srv *netgeartest.Server)
+		args       []string
+		wantState  int
+		wantOutput []string
+		// notWanted must not be part of the output
+		notWanted []string
+		// wantErr is part of the error expected from the mode
+		wantErr string
+	}{
+		{
+			name:       "basic",
+			mode:       ModeBasic,
+			wantState:  check.OK,
+			wantOutput: []string{"CPU Usage: 7.13%", "RAM Usage: 32.46%", "sensor-MAC: 47.0°C", "FAN-1: 2000 RPM"},
+		},
+		{
+			name:       "basic with a hot sensor",
+			mode:       ModeBasic,
+			setup:      func(srv *netgeartest.Server) { srv.SetDeviceInfo(hotSensor) },
+			wantState:  check.Warning,
+			wantOutput: []string{"[WARNING] sensor-System1: 60.0°C"},
+		},
+		{
+			name:       "basic with a custom temperature threshold",
+			mode:       ModeBasic,
+			setup:      func(srv *netgeartest.Server) { srv.SetDeviceInfo(hotSensor) },
+			args:       []string{"--temp-threshold", "sensor-System1=65:75"},
+			wantState:  check.OK,
+			wantOutput: []string{"[OK] sensor-System1: 60.0°C"},
+		},
+		{
+			name:       "basic with a stopped fan",
+			mode:       ModeBasic,
+			setup:      func(srv *netgeartest.Server) { srv.SetDeviceInfo(stoppedFan) },
+			wantState:  check.Critical,
+			wantOutput: []string{"[CRITICAL] FAN-1"},
+		},
+		{
+			name:       "basic after a recent reboot",
+			mode:       ModeBasic,
+			setup:      func(srv *netgeartest.Server) { srv.SetDeviceInfo(rebooted) },
+			args:       []string{"--uptime-warning", "3600:"},
+			wantState:  check.Warning,
+			wantOutput: []string{"[WARNING] Uptime: 10m0s", "uptime=600s;3600:;;0"},
+		},
+		{
+			name:       "basic with an uptime lower bound",
+			mode:       ModeBasic,
+			args:       []string{"--uptime-warning", "3600:"},
+			wantState:  check.OK,
+			wantOutput: []string{"[OK] Uptime: 24h31m29s", "uptime=88289s;3600:;;0"},
+		},
+		{
+			name:       "basic with a stack",
+			mode:       ModeBasic,
+			setup:      func(srv *netgeartest.Server) { srv.SetDeviceInfo(stack) },
+			wantState:  check.OK,
+			wantOutput: []string{"Stack of 2 units"},
+		},
+		{
+			name:       "stack perfdata",
+			mode:       ModeBasic,
+			setup:      func(srv *netgeartest.Server) { srv.SetDeviceInfo(stack) },
+			wantState:  check.OK,
+			wantOutput: []string{"Unit 1: Device Info", "Unit 2: Device Info", "'unit 1 CPU'=7.13", "'unit 2 CPU'=7.13", "'unit 2 uptime'=88289s"},
+		},
+		{
+			name:       "stack with a selected unit",
+			mode:       ModeBasic,
+			setup:      func(srv *netgeartest.Server) { srv.SetDeviceInfo(stack) },
+			args:       []string{"--unit", "2"},
+			wantState:  check.OK,
+			wantOutput: []string{"Unit 2: Device Info", "'unit 2 CPU'=7.13"},
+			notWanted:  []string{"Unit 1:", "unit 1 "},
+		},
+		{
+			name:       "stack with a hidden unit",
+			mode:       ModeBasic,
+			setup:      func(srv *netgeartest.Server) { srv.SetDeviceInfo(stack) },
+			args:       []string{"--nounit", "1"},
+			wantState:  check.OK,
+			wantOutput: []string{"Unit 2: Device Info"},
+			notWanted:  []string{"Unit 1:", "unit 1 "},
+		},
+		{
+			name:    "stack with a unit out of range",
+			mode:    ModeBasic,
+			setup:   func(srv *netgeartest.Server) { srv.SetDeviceInfo(stack) },
+			args:    []string{"--unit", "3"},
+			wantErr: "unit 3 not found, the device has 2 units",
+		},
+		{
+			name:    "single switch with a hidden unit out of range",
+			mode:    ModeBasic,
+			args:    []string{"--nounit", "2"},
+			wantErr: "unit 2 not found, the device has 1 units",
+		},
+		{
+			name:    "stack with all units hidden",
+			mode:    ModeBasic,
+			setup:   func(srv *netgeartest.Server) { srv.SetDeviceInfo(stack) },
+			args:    []string{"--nounit", "1", "--nounit", "2"},
+			wantErr: "all units of the stack are hidden",
+		},
+		{
+			name:       "ports",
+			mode:       ModePorts,
+			wantState:  check.OK,
+			wantOutput: []string{"Port 1", "Port 8", "IN: 0.00% loss"},
+		},
+		{
+			name:       "ports with packet loss",
+			mode:       ModePorts,
+			setup:      func(srv *netgeartest.Server) { srv.SetPortStatistics("inbound", lossyPorts) },
+			wantState:  check.Warning,
+			wantOutput: []string{"[WARNING] IN: 10.00% loss"},
+		},
+		{
+			name:       "ports missing on the device",
+			mode:       ModePorts,
+			args:       []string{"--port", "12"},
+			wantState:  check.Unknown,
+			wantOutput: []string{"Port 12: no statistics returned by the device"},
+		},
+		{
+			name:       "PoE",
+			mode:       ModePoE,
+			wantState:  check.OK,
+			wantOutput: []string{"Port 1 is enabled"},
+		},
+		{
+			name: "PoE endpoint not found",
+			mode: ModePoE,
+			setup: func(srv *netgeartest.Server) {
+				srv.InjectFault(netgeartest.EndpointPoe, netgeartest.Fault{Status: http.StatusNotFound})
+			},
+			wantState:  check.OK,
+			wantOutput: []string{"PoE not supported on M4250-10G2F-PoE+"},
+		},
+		{
+			name:       "PoE on a model known without PoE",
+			mode:       ModePoE,
+			setup:      func(srv *netgeartest.Server) { srv.SetDeviceInfo(nonPoEModel) },
+			wantState:  check.OK,
+			wantOutput: []string{"PoE not supported on M4250-16XF"},
+		},
+		{
+			name:       "PoE on a model without PoE in its name",
+			mode:       ModePoE,
+			setup:      func(srv *netgeartest.Server) { srv.SetDeviceInfo(otherPoEModel) },
+			wantState:  check.OK,
+			wantOutput: []string{"Port 1 is enabled"},
+		},
+		{
+			name: "PoE server error",
+			mode: ModePoE,
+			setup: func(srv *netgeartest.Server) {
+				srv.InjectFault(netgeartest.EndpointPoe, netgeartest.Fault{Status: http.StatusInternalServerError})
+			},
+			wantState:  check.Unknown,
+			wantOutput: []string{"PoE check error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := netgeartest.NewServer()
+			defer srv.Close()
+			if tt.setup != nil {
+				tt.setup(srv)
+			}
+
+			res, err := tt.mode(context.Background(), loggedInClient(t, srv), parseFlags(t, tt.args...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			overall := result.Overall{}
+			overall.AddSubcheck(*res)
+			output := overall.GetOutput()
+			if state := res.GetStatus(); state != tt.wantState {
+				t.Errorf("got state %d, want %d:\n%s", state, tt.wantState, output)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(output, want) {
+					t.Errorf("output does not contain %q:\n%s", want, output)
+				}
+			}
+			for _, unwanted := range tt.notWanted {
+				if strings.Contains(output, unwanted) {
+					t.Errorf("output contains %q:\n%s", unwanted, output)
+				}
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		setup        func(srv *netgeartest.Server)
+		args         []string
+		wantCode     int
+		wantOutput   []string
+		wantStderr   []string
+		wantRequests int
+	}{
+		{
+			name:         "default mode",
+			wantCode:     check.OK,
+			wantOutput:   []string{"Device Info: Uptime", "|uptime=88289s"},
+			wantRequests: 3,
+		},
+		{
+			name:         "all modes",
+			args:         []string{"--mode", "all", "--verbose"},
+			wantCode:     check.OK,
+			wantOutput:   []string{"Device Info", "Ports Statistics", "Power over Ethernet Statistics"},
+			wantStderr:   []string{"4 API requests, 4 attempts"},
+			wantRequests: 6,
+		},
+		{
+			name:         "warning",
+			args:         []string{"--cpu-warning", "5"},
+			wantCode:     check.Warning,
+			wantOutput:   []string{"[WARNING] CPU Usage: 7.13%"},
+			wantRequests: 3,
+		},
+		{
+			name:         "wrong password",
+			args:         []string{"--password", "wrong"},
+			wantCode:     check.Unknown,
+			wantOutput:   []string{"Error while trying to login: invalid username or password"},
+			wantRequests: 1,
+		},
+		{
+			name: "device info error",
+			setup: func(srv *netgeartest.Server) {
+				srv.InjectFault(netgeartest.EndpointDeviceInfo, netgeartest.Fault{Status: http.StatusInternalServerError})
+			},
+			args:         []string{"--verbose"},
+			wantCode:     check.Unknown,
+			wantOutput:   []string{"error retrieving device info"},
+			wantStderr:   []string{"1 API requests, 1 attempts"},
+			wantRequests: 3,
+		},
+		{
+			name: "timeout",
+			setup: func(srv *netgeartest.Server) {
+				srv.InjectFault(netgeartest.EndpointDeviceInfo, netgeartest.Fault{Delay: time.Second})
+			},
+			args:         []string{"--timeout", "100ms"},
+			wantCode:     check.Unknown,
+			wantOutput:   []string{"timeout exceeded"},
+			wantRequests: 3,
+		},
+		{
+			name:       "unknown flag",
+			args:       []string{"--unknown"},
+			wantCode:   check.Unknown,
+			wantStderr: []string{"flag provided but not defined: -unknown"},
+		},
+		{
+			name:       "invalid threshold",
+			args:       []string{"--cpu-warning", "high"},
+			wantCode:   check.Unknown,
+			wantStderr: []string{"invalid value \"high\" for flag -cpu-warning"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := netgeartest.NewServer()
+			defer srv.Close()
+			if tt.setup != nil {
+				tt.setup(srv)
+			}
+
+			args := append([]string{"--hostname", srv.URL, "--username", "admin", "--password", "password"}, tt.args...)
+			var stdout, stderr bytes.Buffer
+			code := run(args, &stdout, &stderr)
+
+			if code != tt.wantCode {
+				t.Errorf("got exit code %d, want %d:\n%s%s", code, tt.wantCode, stdout.String(), stderr.String())
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("output does not contain %q:\n%s", want, stdout.String())
+				}
+			}
+			for _, want := range tt.wantStderr {
+				if !strings.Contains(stderr.String(), want) {
+					t.Errorf("stderr does not contain %q:\n%s", want, stderr.String())
+				}
+			}
+
+			// the login, the requests of the modes and the logout
+			if requests := len(srv.Requests()); requests != tt.wantRequests {
+				t.Errorf("got %d requests, want %d: %v", requests, tt.wantRequests, srv.Requests())
+			}
+			if sessions := srv.Sessions(); sessions != 0 {
+				t.Errorf("expected the session to be closed, %d sessions are open", sessions)
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"timeout", fmt.Errorf("request: %w", context.DeadlineExceeded), "timeout exceeded"},
+		{"signal", context.Canceled, "interrupted by signal"},
+		{
+			"wrong password",
+			fmt.Errorf("login failed: %w: %w", netgear.ErrLoginFailed, &netgear.APIError{StatusCode: 401}),
+			"invalid username or password",
+		},
+		{"expired session", &netgear.APIError{StatusCode: 200, ResponseCode: 401}, "not authorized"},
+		{"forbidden", &netgear.APIError{StatusCode: 403}, "permission denied"},
+		{"not found", &netgear.APIError{StatusCode: 404}, "endpoint not available"},
+		{"rate limited", &netgear.APIError{StatusCode: 429}, "rate limited"},
+		{"other", fmt.Errorf("connection refused"), "connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorMessage(tt.err); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("errorMessage() = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThresholdFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		get  func(*Flags) thresholdFlag
+		want string
+	}{
+		{"temperature default", nil, func(f *Flags) thresholdFlag { return f.TempWarn }, "~:50"},
+		{"plain temperature", []string{"--temp-warning", "55"}, func(f *Flags) thresholdFlag { return f.TempWarn }, "~:55"},
+		{"plain critical temperature", []string{"--temp-critical", "-5.5"}, func(f *Flags) thresholdFlag { return f.TempCrit }, "~:-5.5"},
+		{"temperature range", []string{"--temp-warning", "10:50"}, func(f *Flags) thresholdFlag { return f.TempWarn }, "10:50"},
+		{"temperature lower bound", []string{"--temp-warning", "5:"}, func(f *Flags) thresholdFlag { return f.TempWarn }, "5:"},
+		{"plain CPU", []string{"--cpu-warning", "60"}, func(f *Flags) thresholdFlag { return f.CpuWarn }, "60"},
+		{"disabled", []string{"--temp-warning", ""}, func(f *Flags) thresholdFlag { return f.TempWarn }, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.get(parseFlags(t, tt.args...))
+			if s := got.String(); s != tt.want {
+				t.Errorf("got threshold %q, want %q", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverrideFlag(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		get     func(*Flags) *overrideFlag
+		want    string
+		wantErr bool
+	}{
+		{"temperature", []string{"--temp-threshold", "sensor-MAC=60:75"}, func(f *Flags) *overrideFlag { return &f.TempOverrides }, "sensor-MAC=~:60,~:75", false},
+		{"temperature warning only", []string{"--temp-threshold", "sensor-MAC=60"}, func(f *Flags) *overrideFlag { return &f.TempOverrides }, "sensor-MAC=~:60,", false},
+		{"temperature critical only", []string{"--temp-threshold", "sensor-MAC=:75"}, func(f *Flags) *overrideFlag { return &f.TempOverrides }, "sensor-MAC=,~:75", false},
+		{"fan lower bound", []string{"--fan-threshold", "FAN-2=1500:"}, func(f *Flags) *overrideFlag { return &f.FanOverrides }, "FAN-2=1500:,", false},
+		{"fan lower bounds", []string{"--fan-threshold", "FAN-2=1500:1000"}, func(f *Flags) *overrideFlag { return &f.FanOverrides }, "FAN-2=1500:,1000:", false},
+		{"port", []string{"--port-threshold", "24=1:5"}, func(f *Flags) *overrideFlag { return &f.PortOverrides }, "24=~:1,~:5", false},
+		{
+			"repeated", []string{"--port-threshold", "24=1:5", "--port-threshold", "3=2:4"},
+			func(f *Flags) *overrideFlag { return &f.PortOverrides }, "24=~:1,~:5 3=~:2,~:4", false,
+		},
+		{"ranges", []string{"--temp-threshold", "x=~:60,@75:80"}, func(f *Flags) *overrideFlag { return &f.TempOverrides }, "x=~:60,@75:80", false},
+		{"fan ranges are kept", []string{"--fan-threshold", "FAN-1=~:5000,"}, func(f *Flags) *overrideFlag { return &f.FanOverrides }, "FAN-1=~:5000,", false},
+		{"missing item", []string{"--temp-threshold", "=60:75"}, nil, "", true},
+		{"missing equals sign", []string{"--temp-threshold", "60:75"}, nil, "", true},
+		{"no threshold", []string{"--fan-threshold", "FAN-1="}, nil, "", true},
+		{"no range", []string{"--fan-threshold", "FAN-1=,"}, nil, "", true},
+		{"invalid number", []string{"--port-threshold", "24=one:5"}, nil, "", true},
+		{"invalid range", []string{"--temp-threshold", "x=~:60,hot"}, nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var flags Flags
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			flags.register(fs)
+
+			err := fs.Parse(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error for %v", tt.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s := tt.get(&flags).String(); s != tt.want {
+				t.Errorf("got overrides %q, want %q", s, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package netgear
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Capabilities describes the model and firmware of a switch and the features that depend on them
+type Capabilities struct {
+	Model    string
+	Firmware string
+
+	// PoE is cleared for models known not to supply Power over Ethernet, it is assumed for all others. Whether PoE is
+	// actually supported is only known after requesting the PoE status.
+	PoE bool
+}
+
+// nonPoEModels lists models whose datasheet lists no PoE ports, the M4250-16XF only has SFP+ ports. The model names of
+// the AV Line don't reliably mark the PoE models, so only models listed here are skipped, all others find out from the
+// PoE status request.
+var nonPoEModels = []string{
+	"M4250-16XF",
+}
+
+// Capabilities returns the capabilities of the switch. They are derived from the cached response of DeviceInfo, so no
+// additional request is needed if the device info was read before.
+//
+// Whether the firmware supports paginated port statistics can't be derived from the version and is detected by
+// PortStatistics instead.
+func (n *Netgear) Capabilities(ctx context.Context) (*Capabilities, error) {
+	deviceInfo, err := n.DeviceInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading device capabilities: %w", err)
+	}
+
+	var details DeviceInfoDetails
+	if len(deviceInfo.DeviceInfo.Details) > 0 {
+		details = deviceInfo.DeviceInfo.Details[0]
+	}
+	return capabilitiesOf(details), nil
+}
+
+func capabilitiesOf(details DeviceInfoDetails) *Capabilities {
+	return &Capabilities{
+		Model:    details.Model,
+		Firmware: details.Firmware,
+		PoE: !slices.ContainsFunc(nonPoEModels, func(model string) bool {
+			return strings.EqualFold(model, strings.TrimSpace(details.Model))
+		}),
+	}
+}
+
+// String returns the model, or a placeholder if the switch did not report it
+func (c *Capabilities) String() string {
+	if c.Model == "" {
+		return "this device"
+	}
+	return c.Model
+}
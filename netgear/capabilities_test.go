@@ -0,0 +1,32 @@
+package netgear
+
+import "testing"
+
+func TestCapabilitiesOf(t *testing.T) {
+	tests := []struct {
+		model   string
+		wantPoE bool
+	}{
+		{"M4250-10G2F-PoE+", true},
+		// PoE models without PoE in their name must not be skipped
+		{"GSM4248P", true},
+		{"M4250-26G4F-POE+", true},
+		{"", true},
+		{"M4250-16XF", false},
+		{"m4250-16xf", false},
+		// PoE++ model without PoE in its name
+		{"M4350-16V4C", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			c := capabilitiesOf(DeviceInfoDetails{Model: tt.model, Firmware: "13.0.4.26"})
+			if c.PoE != tt.wantPoE {
+				t.Errorf("PoE = %v, want %v", c.PoE, tt.wantPoE)
+			}
+			if c.Model != tt.model || c.Firmware != "13.0.4.26" {
+				t.Errorf("unexpected capabilities %+v", c)
+			}
+		})
+	}
+}
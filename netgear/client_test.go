@@ -0,0 +1,337 @@
+package netgear_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/icinga/check-netgear/netgear"
+	"github.com/icinga/check-netgear/netgear/netgeartest"
+)
+
+// newClient returns a client for srv using its default credentials, it is not logged in yet
+func newClient(t *testing.T, srv *netgeartest.Server, opts ...netgear.Option) *netgear.Netgear {
+	t.Helper()
+
+	n, err := netgear.NewNetgear(srv.URL, "admin", "password", opts...)
+	if err != nil {
+		t.Fatalf("NewNetgear: %v", err)
+	}
+	return n
+}
+
+// countRequests returns how often request, e.g. "POST /api/v1/login", was sent to srv
+func countRequests(srv *netgeartest.Server, request string) int {
+	count := 0
+	for _, r := range srv.Requests() {
+		if r == request {
+			count++
+		}
+	}
+	return count
+}
+
+func TestLoginFailed(t *testing.T) {
+	srv := netgeartest.NewServer()
+	defer srv.Close()
+	srv.Password = "other"
+
+	err := newClient(t, srv).Login(context.Background())
+	if !errors.Is(err, netgear.ErrLoginFailed) {
+		t.Fatalf("expected ErrLoginFailed, got %v", err)
+	}
+	if !errors.Is(err, netgear.ErrUnauthorized) {
+		t.Errorf("expected the error to match ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestLoginServerError(t *testing.T) {
+	srv := netgeartest.NewServer()
+	defer srv.Close()
+	srv.InjectFault(netgeartest.EndpointLogin, netgeartest.Fault{Status: http.StatusInternalServerError})
+
+	err := newClient(t, srv).Login(context.Background())
+	if err == nil || errors.Is(err, netgear.ErrLoginFailed) {
+		t.Fatalf("expected an error other than ErrLoginFailed, got %v", err)
+	}
+}
+
+func TestSessionRejectedInEnvelope(t *testing.T) {
+	srv := netgeartest.NewServer()
+	defer srv.Close()
+
+	n := newClient(t, srv)
+	ctx := context.Background()
+	if err := n.Login(ctx); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	// some firmware reports an expired session with HTTP status 200 and the failure in the envelope only
+	srv.InjectFault(netgeartest.EndpointDeviceInfo, netgeartest.Fault{
+		Body:  `{"resp":{"status":"failure","respCode":401,"respMsg":"Invalid session"}}`,
+		Times: 1,
+	})
+
+	if _, err := n.DeviceInfo(ctx); err != nil {
+		t.Fatalf("DeviceInfo: %v", err)
+	}
+	if logins := countRequests(srv, "POST /api/v1/login"); logins != 2 {
+		t.Errorf("expected a second login after the rejected session, got %d logins", logins)
+	}
+	// the repetition after the login belongs to the same API request
+	if requests, attempts := n.Stats(); requests != 1 || attempts != 2 {
+		t.Errorf("Stats() = %d, %d, want 1, 2", requests, attempts)
+	}
+}
+
+func TestForbiddenNotRetriedWithLogin(t *testing.T) {
+	srv := netgeartest.NewServer()
+	defer srv.Close()
+
+	n := newClient(t, srv)
+	ctx := context.Background()
+	if err := n.Login(ctx); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	// a missing permission is reported with 403, the session itself is still valid
+	srv.InjectFault(netgeartest.EndpointPoe, netgeartest.Fault{
+		Status: http.StatusForbidden,
+		Body:   `{"resp":{"status":"failure","respCode":403,"respMsg":"Access denied"}}`,
+	})
+
+	if _, err := n.PoeStatus(ctx); !errors.Is(err, netgear.ErrForbidden) || errors.Is(err, netgear.ErrUnauthorized) {
+		t.Fatalf("expected ErrForbidden only, got %v", err)
+	}
+	if logins := countRequests(srv, "POST /api/v1/login"); logins != 1 {
+		t.Errorf("expected no login after a forbidden request, got %d logins", logins)
+	}
+}
+
+// authenticate runs Authenticate and a request like a plugin run using the session cache
+func authenticate(t *testing.T, srv *netgeartest.Server, cache *netgear.SessionCache) {
+	t.Helper()
+
+	n := newClient(t, srv, netgear.WithSessionCache(cache))
+	ctx := context.Background()
+	if err := n.Authenticate(ctx); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if _, err := n.DeviceInfo(ctx); err != nil {
+		t.Fatalf("DeviceInfo: %v", err)
+	}
+}
+
+func TestSessionCacheRefreshesExpiry(t *testing.T) {
+	srv := netgeartest.NewServer()
+	defer srv.Close()
+
+	const ttl = 500 * time.Millisecond
+	cache, err := netgear.NewSessionCache(t.TempDir(), ttl)
+	if err != nil {
+		t.Fatalf("NewSessionCache: %v", err)
+	}
+
+	// every run reuses the token before it expires, so only the first one logs in although the runs take longer than
+	// the TTL in total
+	for range 4 {
+		authenticate(t, srv, cache)
+		time.Sleep(ttl / 2)
+	}
+
+	if logins := countRequests(srv, "POST /api/v1/login"); logins != 1 {
+		t.Errorf("expected a single login, got %d", logins)
+	}
+}
+
+func TestSessionCacheLogsOutExpiredToken(t *testing.T) {
+	srv := netgeartest.NewServer()
+	defer srv.Close()
+
+	const ttl = 100 * time.Millisecond
+	cache, err := netgear.NewSessionCache(t.TempDir(), ttl)
+	if err != nil {
+		t.Fatalf("NewSessionCache: %v", err)
+	}
+
+	authenticate(t, srv, cache)
+	time.Sleep(2 * ttl)
+	authenticate(t, srv, cache)
+
+	if logins := countRequests(srv, "POST /api/v1/login"); logins != 2 {
+		t.Errorf("expected a new login for the expired token, got %d logins", logins)
+	}
+	if logouts := countRequests(srv, "GET /api/v1/logout"); logouts != 1 {
+		t.Errorf("expected the expired token to be logged out, got %d logouts", logouts)
+	}
+	if sessions := srv.Sessions(); sessions != 1 {
+		t.Errorf("expected a single open session, got %d", sessions)
+	}
+}
+
+func TestSessionCacheStoreFailed(t *testing.T) {
+	srv := netgeartest.NewServer()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cache, err := netgear.NewSessionCache(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("NewSessionCache: %v", err)
+	}
+	authenticate(t, srv, cache)
+
+	// replace the cache file by a non-empty directory, so storing the next token fails even when running as root
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected a single cache file, got %v, %v", files, err)
+	}
+	if err := os.Remove(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(files[0], "blocked"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	before := srv.Sessions()
+	if err := newClient(t, srv, netgear.WithSessionCache(cache)).Authenticate(context.Background()); err == nil {
+		t.Fatal("expected an error storing the session")
+	}
+	if sessions := srv.Sessions(); sessions != before {
+		t.Errorf("expected the uncached session to be logged out, got %d open sessions, want %d", sessions, before)
+	}
+}
+
+func TestTraceRedactsSecrets(t *testing.T) {
+	srv := netgeartest.NewServer()
+	defer srv.Close()
+	srv.Password = "s3cret-password"
+
+	var trace bytes.Buffer
+	n, err := netgear.NewNetgear(srv.URL, "admin", srv.Password, netgear.WithTrace(&trace))
+	if err != nil {
+		t.Fatalf("NewNetgear: %v", err)
+	}
+	ctx := context.Background()
+	if err := n.Login(ctx); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if _, err := n.DeviceInfo(ctx); err != nil {
+		t.Fatalf("DeviceInfo: %v", err)
+	}
+
+	out := trace.String()
+	if strings.Contains(out, srv.Password) {
+		t.Errorf("trace contains the password:\n%s", out)
+	}
+	// the fake server uses 32 hex digits as session token, it is sent in the login response and the session header
+	if regexp.MustCompile(`[0-9a-f]{32}`).MatchString(out) {
+		t.Errorf("trace contains the session token:\n%s", out)
+	}
+	if !strings.Contains(out, "Session: <redacted>") || !strings.Contains(out, `"session":"<redacted>"`) {
+		t.Errorf("expected the session token to be redacted:\n%s", out)
+	}
+	if !strings.Contains(out, "--> GET "+srv.URL+"/api/v1/device_info") {
+		t.Errorf("expected the device info request to be traced:\n%s", out)
+	}
+}
+
+func TestDeviceInfoCached(t *testing.T) {
+	srv := netgeartest.NewServer()
+	defer srv.Close()
+
+	n := newClient(t, srv)
+	ctx := context.Background()
+	if err := n.Login(ctx); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	// the checks run in parallel and may ask for the capabilities before the device info arrived
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			if _, err := n.Capabilities(ctx); err != nil {
+				t.Errorf("Capabilities: %v", err)
+			}
+			if _, err := n.DeviceInfo(ctx); err != nil {
+				t.Errorf("DeviceInfo: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if requests := countRequests(srv, "GET /api/v1/device_info"); requests != 1 {
+		t.Errorf("expected a single device info request, got %d", requests)
+	}
+}
+
+func TestDeviceInfoErrorNotCached(t *testing.T) {
+	srv := netgeartest.NewServer()
+	defer srv.Close()
+	srv.InjectFault(netgeartest.EndpointDeviceInfo, netgeartest.Fault{Status: http.StatusInternalServerError, Times: 1})
+
+	n := newClient(t, srv)
+	ctx := context.Background()
+	if err := n.Login(ctx); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if _, err := n.Capabilities(ctx); err == nil {
+		t.Fatal("expected the injected fault to fail the request")
+	}
+	caps, err := n.Capabilities(ctx)
+	if err != nil {
+		t.Fatalf("Capabilities: %v", err)
+	}
+	if caps.Model != "M4250-10G2F-PoE+" || !caps.PoE {
+		t.Errorf("unexpected capabilities %+v", caps)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	srv := netgeartest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	recorder := newClient(t, srv, netgear.WithRecorder(dir))
+	if err := recorder.Login(ctx); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	want, err := recorder.DeviceInfo(ctx)
+	if err != nil {
+		t.Fatalf("DeviceInfo: %v", err)
+	}
+	if _, err := recorder.PortStatistics(ctx, "inbound", nil); err != nil {
+		t.Fatalf("PortStatistics: %v", err)
+	}
+
+	replay, err := netgear.NewNetgear("replay", "replay", "replay", netgear.WithReplay(dir))
+	if err != nil {
+		t.Fatalf("NewNetgear: %v", err)
+	}
+	if err := replay.Login(ctx); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	got, err := replay.DeviceInfo(ctx)
+	if err != nil {
+		t.Fatalf("DeviceInfo: %v", err)
+	}
+	if got.DeviceInfo.Details[0].Model != want.DeviceInfo.Details[0].Model {
+		t.Errorf("replayed model %q, want %q", got.DeviceInfo.Details[0].Model, want.DeviceInfo.Details[0].Model)
+	}
+	stats, err := replay.PortStatistics(ctx, "inbound", nil)
+	if err != nil || len(stats.PortStatistics.Rows) != 8 {
+		t.Errorf("expected 8 replayed ports, got %v", err)
+	}
+	if _, err := replay.PoeStatus(ctx); !errors.Is(err, netgear.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a response that was not recorded, got %v", err)
+	}
+}
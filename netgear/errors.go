@@ -0,0 +1,107 @@
+package netgear
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrUnauthorized is returned when the switch rejects the credentials or the session token
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when the switch accepts the session but denies the request, e.g. because the user lacks
+	// the permission. Logging in again doesn't help.
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound is returned when the requested endpoint does not exist on the switch
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited is returned when the switch refuses the request because of too many requests
+	ErrRateLimited = errors.New("rate limited")
+	// ErrLoginFailed is returned by Login when the switch rejects the username or the password
+	ErrLoginFailed = errors.New("invalid username or password")
+)
+
+// APIError describes a request that was answered by the switch with an error, either by the HTTP status code or by
+// the response envelope contained in the body
+type APIError struct {
+	StatusCode   int
+	ResponseCode int
+	Message      string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("HTTP %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.ResponseCode != 0 {
+		msg += fmt.Sprintf(" (response code %d)", e.ResponseCode)
+	}
+	return msg
+}
+
+// Is allows matching an APIError against the sentinel errors of this package using errors.Is. Both the HTTP status
+// code and the response code are taken into account, as the switch also reports failures like an expired session with
+// HTTP status 200 and uses the HTTP status codes as response codes in the envelope.
+//
+// A 403 only matches ErrUnauthorized if the response code marks the session as invalid, otherwise it is ErrForbidden,
+// so a missing permission doesn't cause the client to log in again.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.hasCode(http.StatusUnauthorized)
+	case ErrForbidden:
+		return e.hasCode(http.StatusForbidden) && !e.hasCode(http.StatusUnauthorized)
+	case ErrNotFound:
+		return e.hasCode(http.StatusNotFound)
+	case ErrRateLimited:
+		return e.hasCode(http.StatusTooManyRequests)
+	default:
+		return false
+	}
+}
+
+// hasCode reports whether either the status code or the response code is one of codes
+func (e *APIError) hasCode(codes ...int) bool {
+	for _, code := range codes {
+		if e.StatusCode == code || e.ResponseCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// responseEnvelope is the status information the switch adds to every JSON response
+type responseEnvelope struct {
+	Resp *struct {
+		Status   string `json:"status"`
+		RespCode int    `json:"respCode"`
+		RespMsg  string `json:"respMsg"`
+	} `json:"resp"`
+}
+
+// checkResponse returns an APIError if either the HTTP status code or the response envelope of body indicate a failure
+func checkResponse(statusCode int, body []byte) error {
+	var envelope responseEnvelope
+	// the body is not required to be valid JSON here, decoding errors are reported by the caller
+	_ = json.Unmarshal(body, &envelope)
+
+	if statusCode < 200 || statusCode > 299 {
+		apiErr := &APIError{StatusCode: statusCode}
+		if envelope.Resp != nil {
+			apiErr.ResponseCode = envelope.Resp.RespCode
+			apiErr.Message = envelope.Resp.RespMsg
+		}
+		return apiErr
+	}
+
+	if envelope.Resp != nil && envelope.Resp.Status != "" && envelope.Resp.Status != "success" {
+		return &APIError{
+			StatusCode:   statusCode,
+			ResponseCode: envelope.Resp.RespCode,
+			Message:      envelope.Resp.RespMsg,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package netgear
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    *APIError
+		target error
+		want   bool
+	}{
+		{"status 401", &APIError{StatusCode: 401}, ErrUnauthorized, true},
+		{"status 403", &APIError{StatusCode: 403}, ErrForbidden, true},
+		{"status 403 is no auth error", &APIError{StatusCode: 403}, ErrUnauthorized, false},
+		{"status 403 with invalid session", &APIError{StatusCode: 403, ResponseCode: 401}, ErrUnauthorized, true},
+		{"status 403 with invalid session is not forbidden", &APIError{StatusCode: 403, ResponseCode: 401}, ErrForbidden, false},
+		{"status 404", &APIError{StatusCode: 404}, ErrNotFound, true},
+		{"status 429", &APIError{StatusCode: 429}, ErrRateLimited, true},
+		{"response code 401", &APIError{StatusCode: 200, ResponseCode: 401}, ErrUnauthorized, true},
+		{"response code 403", &APIError{StatusCode: 200, ResponseCode: 403}, ErrForbidden, true},
+		{"response code 404", &APIError{StatusCode: 200, ResponseCode: 404}, ErrNotFound, true},
+		{"response code 429", &APIError{StatusCode: 200, ResponseCode: 429}, ErrRateLimited, true},
+		{"status 500", &APIError{StatusCode: 500}, ErrUnauthorized, false},
+		{"response code 404 is no auth error", &APIError{StatusCode: 200, ResponseCode: 404}, ErrUnauthorized, false},
+		{"response code 1", &APIError{StatusCode: 200, ResponseCode: 1}, ErrNotFound, false},
+		{"other target", &APIError{StatusCode: 401}, errors.New("unauthorized"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.err, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   *APIError
+	}{
+		{"success", 200, `{"resp":{"status":"success","respCode":0}}`, nil},
+		{"no envelope", 200, `{"deviceInfo":{}}`, nil},
+		{"invalid JSON", 200, `not json`, nil},
+		{
+			"failure envelope", 200, `{"resp":{"status":"failure","respCode":401,"respMsg":"Invalid session"}}`,
+			&APIError{StatusCode: 200, ResponseCode: 401, Message: "Invalid session"},
+		},
+		{
+			"HTTP error with envelope", 404, `{"resp":{"status":"failure","respCode":404,"respMsg":"Not found"}}`,
+			&APIError{StatusCode: 404, ResponseCode: 404, Message: "Not found"},
+		},
+		{"HTTP error without body", 502, ``, &APIError{StatusCode: 502}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkResponse(tt.status, []byte(tt.body))
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected *APIError, got %v", err)
+			}
+			if *apiErr != *tt.want {
+				t.Errorf("got %+v, want %+v", *apiErr, *tt.want)
+			}
+		})
+	}
+}
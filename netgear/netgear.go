@@ -2,44 +2,95 @@ package netgear
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const timeout = 10 * time.Second
+const defaultTimeout = 10 * time.Second
 
 type Netgear struct {
 	sessionToken string
 	client       *http.Client
+	userAgent    string
+	logger       *slog.Logger
+
+	// sessionMu guards sessionToken, renewMu makes sure that parallel requests rejected at the same time only cause a
+	// single new login
+	sessionMu sync.RWMutex
+	renewMu   sync.Mutex
+	// limiter restricts the number of requests running in parallel, nil means no restriction
+	limiter chan struct{}
+
+	// deviceInfo is the first device info read, see DeviceInfo
+	deviceInfoMu sync.Mutex
+	deviceInfo   *DeviceInfo
+
+	sessionCache *SessionCache
+	// refreshCache is set when a token was loaded from the session cache, its expiry is extended by the first
+	// successful request using it
+	refreshCache atomic.Bool
+	retryPolicy  RetryPolicy
+
+	// requests and attempts count the API requests and the HTTP requests including retries, login and logout are not
+	// counted
+	requests atomic.Int64
+	attempts atomic.Int64
 
 	baseUrl  *url.URL
 	username string
 	password string
 }
 
-func NewNetgear(baseUrl, username, password string) (*Netgear, error) {
-	u, err := url.Parse(strings.TrimSpace(baseUrl))
+// NewNetgear creates a new API client for the switch at baseUrl, see ParseBaseURL for the accepted formats. The client
+// is safe for concurrent use.
+func NewNetgear(baseUrl, username, password string, opts ...Option) (*Netgear, error) {
+	u, err := ParseBaseURL(baseUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	o := options{
+		userAgent:   defaultUserAgent,
+		logger:      slog.New(slog.DiscardHandler),
+		retryPolicy: DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	client, err := o.newHTTPClient()
 	if err != nil {
 		return nil, err
 	}
-	// be sure that the base path is /api/v1 using JoinPath
-	u = u.JoinPath("api", "v1")
 
-	return &Netgear{
-		client:   &http.Client{Timeout: timeout},
-		baseUrl:  u,
-		username: username,
-		password: password,
-	}, nil
+	n := &Netgear{
+		client:       client,
+		userAgent:    o.userAgent,
+		logger:       o.logger,
+		sessionCache: o.sessionCache,
+		retryPolicy:  o.retryPolicy,
+		baseUrl:      u,
+		username:     username,
+		password:     password,
+	}
+	if o.maxParallel > 0 {
+		n.limiter = make(chan struct{}, o.maxParallel)
+	}
+	return n, nil
 }
 
-func (n *Netgear) Login() error {
+func (n *Netgear) Login(ctx context.Context) error {
 	loginPayload := map[string]interface{}{
 		"user": map[string]string{
 			"name":     n.username,
@@ -53,11 +104,12 @@ func (n *Netgear) Login() error {
 	}
 
 	loginURL := n.baseUrl.JoinPath("login")
-	req, err := http.NewRequest(http.MethodPost, loginURL.String(), bytes.NewBuffer(payloadBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, loginURL.String(), bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", n.userAgent)
 
 	resp, err := n.client.Do(req)
 	if err != nil {
@@ -68,8 +120,19 @@ func (n *Netgear) Login() error {
 		_ = resp.Body.Close()
 	}()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read login response: %w", err)
+	}
+	if err := checkResponse(resp.StatusCode, body); err != nil {
+		if errors.Is(err, ErrUnauthorized) {
+			return fmt.Errorf("login failed: %w: %w", ErrLoginFailed, err)
+		}
+		return fmt.Errorf("login failed: %w", err)
+	}
+
 	var result map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.Unmarshal(body, &result); err != nil {
 		return fmt.Errorf("failed to parse login response JSON: %w", err)
 	}
 
@@ -88,83 +151,237 @@ func (n *Netgear) Login() error {
 		return fmt.Errorf("login response: missing 'session' token")
 	}
 
-	n.sessionToken, ok = session.(string)
+	token, ok := session.(string)
 	if !ok {
 		return fmt.Errorf("login response: 'session' token is not a string (got %T)", session)
 	}
+	n.setToken(token)
 
 	return nil
 }
 
-func (n *Netgear) Logout() error {
-	logoutURL := n.baseUrl.JoinPath("logout")
-	resp, err := n.client.Get(logoutURL.String())
+func (n *Netgear) token() string {
+	n.sessionMu.RLock()
+	defer n.sessionMu.RUnlock()
+	return n.sessionToken
+}
+
+func (n *Netgear) setToken(token string) {
+	n.sessionMu.Lock()
+	defer n.sessionMu.Unlock()
+	n.sessionToken = token
+}
+
+// Stats returns the number of API requests performed so far and the number of attempts needed for them
+func (n *Netgear) Stats() (requests, attempts int64) {
+	return n.requests.Load(), n.attempts.Load()
+}
+
+// Authenticate reuses a cached session token if a session cache is set and contains a valid token for this switch and
+// user. The expiry of a reused token is extended once a request using it succeeded. Otherwise, it performs a fresh
+// Login and stores the new token in the cache, an expired token is logged out before.
+func (n *Netgear) Authenticate(ctx context.Context) error {
+	if n.sessionCache == nil {
+		return n.Login(ctx)
+	}
+
+	unlock, err := n.sessionCache.lock(ctx, n.baseUrl.String(), n.username)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	session, ok := n.sessionCache.load(n.baseUrl.String(), n.username)
+	if ok && !session.expired() {
+		n.setToken(session.Token)
+		n.refreshCache.Store(true)
+		return nil
+	}
+	if ok {
+		n.logoutExpired(ctx, session.Token)
+	}
+
+	return n.loginAndCache(ctx)
+}
+
+// logoutExpired ends the session of an expired cached token, so it doesn't stay open on the switch until it times out.
+// Errors are only logged, the token may have been invalidated by the switch already.
+func (n *Netgear) logoutExpired(ctx context.Context, token string) {
+	if err := n.send(ctx, http.MethodGet, n.baseUrl.JoinPath("logout"), token, nil); err != nil {
+		n.logger.Debug("logout of expired cached session failed", "error", err)
+	}
+}
+
+// refreshCachedSession extends the expiry of the cached token, unless a parallel run replaced it in the meantime
+func (n *Netgear) refreshCachedSession(ctx context.Context) {
+	unlock, err := n.sessionCache.lock(ctx, n.baseUrl.String(), n.username)
+	if err != nil {
+		n.logger.Debug("error refreshing cached session", "error", err)
+		return
+	}
+	defer unlock()
+
+	token := n.token()
+	if session, ok := n.sessionCache.load(n.baseUrl.String(), n.username); !ok || session.Token != token {
+		return
+	}
+	if err := n.sessionCache.store(n.baseUrl.String(), n.username, token); err != nil {
+		n.logger.Debug("error refreshing cached session", "error", err)
+	}
+}
+
+// renewSession replaces the rejected session token by logging in again. If a parallel request already renewed the
+// session, nothing is done. With a session cache, a different token already stored by a parallel run is used instead
+// of logging in again.
+func (n *Netgear) renewSession(ctx context.Context, rejected string) error {
+	n.renewMu.Lock()
+	defer n.renewMu.Unlock()
+
+	if n.token() != rejected {
+		return nil
+	}
+	n.logger.Debug("session rejected by the switch, logging in again")
+
+	if n.sessionCache == nil {
+		return n.Login(ctx)
+	}
+
+	unlock, err := n.sessionCache.lock(ctx, n.baseUrl.String(), n.username)
 	if err != nil {
 		return err
 	}
-	_, _ = io.Copy(io.Discard, resp.Body)
-	_ = resp.Body.Close()
+	defer unlock()
+
+	session, ok := n.sessionCache.load(n.baseUrl.String(), n.username)
+	if ok && session.Token != rejected {
+		if !session.expired() {
+			n.setToken(session.Token)
+			return nil
+		}
+		n.logoutExpired(ctx, session.Token)
+	}
+
+	return n.loginAndCache(ctx)
+}
+
+// loginAndCache performs a fresh Login and stores the new token in the session cache. If the token can't be stored,
+// the session is logged out again: with a session cache the plugin keeps its session open, and no later run would
+// know the token to reuse or end it.
+func (n *Netgear) loginAndCache(ctx context.Context) error {
+	if err := n.Login(ctx); err != nil {
+		return err
+	}
+	if err := n.sessionCache.store(n.baseUrl.String(), n.username, n.token()); err != nil {
+		if err := n.send(ctx, http.MethodGet, n.baseUrl.JoinPath("logout"), n.token(), nil); err != nil {
+			n.logger.Debug("logout of uncached session failed", "error", err)
+		}
+		n.setToken("")
+		return fmt.Errorf("error storing session in cache: %w", err)
+	}
 	return nil
 }
 
-func (n *Netgear) DeviceInfo() (*DeviceInfo, error) {
-	var di DeviceInfo
-	if err := n.doRequest(http.MethodGet, "device_info", &di); err != nil {
-		return nil, err
+// Logout ends the current session on the switch. It is neither retried nor does it log in again, so an error means
+// that the session may still be open.
+func (n *Netgear) Logout(ctx context.Context) error {
+	if n.token() == "" {
+		return nil
 	}
-	return &di, nil
+
+	if err := n.send(ctx, http.MethodGet, n.baseUrl.JoinPath("logout"), n.token(), nil); err != nil {
+		return fmt.Errorf("logout failed: %w", err)
+	}
+	n.setToken("")
+
+	if n.sessionCache != nil {
+		return n.sessionCache.remove(n.baseUrl.String(), n.username)
+	}
+	return nil
 }
 
-func (n *Netgear) PortStatistics(statType string) (*PortStatistics, error) {
-	const defaultPageIndex = 1
-	const defaultPageSize = 25
+// DeviceInfo returns the model, the firmware and the state of the hardware of the switch. The response is read once
+// and cached for further calls, so the checks of a plugin run and Capabilities share a single request. The result must
+// not be modified.
+func (n *Netgear) DeviceInfo(ctx context.Context) (*DeviceInfo, error) {
+	n.deviceInfoMu.Lock()
+	defer n.deviceInfoMu.Unlock()
 
-	// NOTE: There should be a paginated request here!
-	// However, as of 2025-11-25, the API does not respect the parameters "indexPage" and "pageSize" and always
-	// returns the first page. Therefore, this request only works for switches with less than 25 ports.
-	u := n.baseUrl.JoinPath("port_statistics")
-	q := u.Query()
-	q.Set("type", statType)
-	q.Set("indexPage", strconv.Itoa(defaultPageIndex))
-	q.Set("pageSize", strconv.Itoa(defaultPageSize))
-	u.RawQuery = q.Encode()
+	if n.deviceInfo != nil {
+		return n.deviceInfo, nil
+	}
 
-	var stats PortStatistics
-	if err := n.doRequestURL(http.MethodGet, u, &stats); err != nil {
+	var di DeviceInfo
+	if err := n.doRequest(ctx, http.MethodGet, "device_info", &di); err != nil {
 		return nil, err
 	}
-	return &stats, nil
+	n.deviceInfo = &di
+	return n.deviceInfo, nil
 }
 
-func (n *Netgear) PoeStatus() (*PoeStatus, error) {
+func (n *Netgear) PoeStatus(ctx context.Context) (*PoeStatus, error) {
 	poeStatus := new(PoeStatus)
-	if err := n.doRequest(http.MethodGet, "swcfg_poe", poeStatus); err != nil {
+	if err := n.doRequest(ctx, http.MethodGet, "swcfg_poe", poeStatus); err != nil {
 		return nil, err
 	}
 	return poeStatus, nil
 }
 
-// doRequestURL Performs an HTTP-Request to a given path on the previously defined host and stores the resulting json
-// response in the object provided by the result parameter.
+// doRequest Performs an HTTP-Request to a given path on the previously defined host and stores the resulting json
+// response in the object provided by the result parameter. The request is aborted once ctx is done.
 //
 // Note: setting the result parameter to nil causes the parsing of the response to be skipped, the request is still
 // performed.
-func (n *Netgear) doRequest(method, path string, result any) error {
-	return n.doRequestURL(method, n.baseUrl.JoinPath(path), result)
+func (n *Netgear) doRequest(ctx context.Context, method, path string, result any) error {
+	return n.doRequestURL(ctx, method, n.baseUrl.JoinPath(path), result)
 }
 
 // doRequestURL Performs an HTTP-Request to a given URL and stores the resulting json response in the
-// object provided by the result parameter.
+// object provided by the result parameter. The request is aborted once ctx is done.
 //
 // Note: setting the result parameter to nil causes the parsing of the response to be skipped, the request is still
 // performed.
-func (n *Netgear) doRequestURL(method string, u *url.URL, result any) error {
-	req, err := http.NewRequest(method, u.String(), nil)
+//
+// Responses with a non-2xx status code or a failed response envelope are returned as *APIError. If the switch rejects
+// the session, e.g. because it expired, the client logs in again and repeats the request once. Transient errors of
+// GET requests are retried according to the retry policy.
+func (n *Netgear) doRequestURL(ctx context.Context, method string, u *url.URL, result any) error {
+	// counted once, the attempts of the repetition after a new login are counted by sendWithRetry
+	n.requests.Add(1)
+	send := func() error { return n.send(ctx, method, u, n.token(), result) }
+
+	token := n.token()
+	err := n.sendWithRetry(ctx, method, send)
+	if err == nil && n.refreshCache.CompareAndSwap(true, false) {
+		n.refreshCachedSession(ctx)
+	}
+	if !errors.Is(err, ErrUnauthorized) {
+		return err
+	}
+
+	if err := n.renewSession(ctx, token); err != nil {
+		return fmt.Errorf("session rejected and login failed: %w", err)
+	}
+	return n.sendWithRetry(ctx, method, send)
+}
+
+// send performs a single request for doRequestURL using the given session token
+func (n *Netgear) send(ctx context.Context, method string, u *url.URL, token string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("session", n.sessionToken)
+	req.Header.Set("session", token)
+	req.Header.Set("User-Agent", n.userAgent)
+
+	if n.limiter != nil {
+		select {
+		case n.limiter <- struct{}{}:
+			defer func() { <-n.limiter }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 
 	resp, err := n.client.Do(req)
 	if err != nil {
@@ -175,17 +392,29 @@ func (n *Netgear) doRequestURL(method string, u *url.URL, result any) error {
 		_ = resp.Body.Close()
 	}()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := checkResponse(resp.StatusCode, body); err != nil {
+		return err
+	}
+
 	if result == nil {
 		return nil
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+	if err := json.Unmarshal(body, result); err != nil {
 		return fmt.Errorf("failed to parse response JSON: %w", err)
 	}
+	annotateNumbers(result)
 
 	return nil
 }
 
+// StringPercentToFloat parses a percentage like "7.13%" as sent by older firmware.
+//
+// Deprecated: DeviceInfo decodes the CPU and memory usage as Number, use Number.Float64 instead.
 func StringPercentToFloat(percents string) (float64, error) {
 	return strconv.ParseFloat(strings.TrimSuffix(percents, "%"), 64)
 }
@@ -0,0 +1,366 @@
+// Package netgeartest provides an in-process fake of the AV Line management API for tests of code using the netgear
+// package.
+package netgeartest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/icinga/check-netgear/netgear"
+)
+
+// Endpoints served by Server, used as keys for InjectFault
+const (
+	EndpointLogin          = "login"
+	EndpointLogout         = "logout"
+	EndpointDeviceInfo     = "device_info"
+	EndpointPortStatistics = "port_statistics"
+	EndpointPoe            = "swcfg_poe"
+)
+
+// Fault changes how the server responds to requests of an endpoint
+type Fault struct {
+	// Delay is waited before responding, e.g. to trigger timeouts
+	Delay time.Duration
+	// Status is the HTTP status code to respond with instead of the regular response
+	Status int
+	// Body is sent instead of the regular response, e.g. to send malformed JSON
+	Body string
+	// Times limits the fault to the given number of requests, zero means all requests
+	Times int
+}
+
+// Server is a fake AV Line switch. The fixture data and faults can be changed while the server is running.
+type Server struct {
+	*httptest.Server
+
+	Username string
+	Password string
+
+	// IgnorePagination makes the server always return the first page of the port statistics like older firmware. It
+	// has to be set before the first request.
+	IgnorePagination bool
+	// PortQuery enables querying the statistics of a single port using the parameter "portId". It has to be set
+	// before the first request.
+	PortQuery bool
+
+	mu         sync.Mutex
+	deviceInfo netgear.DeviceInfo
+	ports      map[string][]netgear.PortStatisticRow
+	poe        netgear.PoeStatus
+	faults     map[string]*Fault
+	sessions   map[string]bool
+	requests   []string
+}
+
+// NewServer starts a fake switch with the credentials "admin"/"password" serving DefaultDeviceInfo, 8 ports without
+// errors and DefaultPoeStatus. The server has to be closed by the caller.
+func NewServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewServer(s.handler())
+	return s
+}
+
+// NewTLSServer is like NewServer, but serves HTTPS using a self-signed certificate, see httptest.Server.Client
+func NewTLSServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewTLSServer(s.handler())
+	return s
+}
+
+func newServer() *Server {
+	return &Server{
+		Username:   "admin",
+		Password:   "password",
+		deviceInfo: DefaultDeviceInfo(),
+		ports: map[string][]netgear.PortStatisticRow{
+			"inbound":  PortRows(8),
+			"outbound": PortRows(8),
+		},
+		poe:      DefaultPoeStatus(),
+		faults:   make(map[string]*Fault),
+		sessions: make(map[string]bool),
+	}
+}
+
+// DefaultDeviceInfo returns the device info of a healthy switch with a single unit
+func DefaultDeviceInfo() netgear.DeviceInfo {
+	var di netgear.DeviceInfo
+	di.DeviceInfo.Details = []netgear.DeviceInfoDetails{
+		{Uptime: "1 days, 0 hrs, 31 mins, 29 secs", Model: "M4250-10G2F-PoE+", Firmware: "13.0.4.26"},
+	}
+	di.DeviceInfo.Fan = []netgear.Fan{{Details: []netgear.FanDetail{
+		{Description: "FAN-1", Speed: netgear.NewNumber(2000)},
+	}}}
+	di.DeviceInfo.Sensor = []netgear.Sensor{{Details: []netgear.SensorDetail{
+		{Description: "sensor-System1", Temperature: netgear.NewNumber(44), MaxTemp: netgear.NewNumber(85)},
+		{Description: "sensor-MAC", Temperature: netgear.NewNumber(47), MaxTemp: netgear.NewNumber(105)},
+	}}}
+	di.DeviceInfo.Cpu = []netgear.Usage{{Usage: netgear.NewNumber(7.13), Unit: 1}}
+	di.DeviceInfo.Memory = []netgear.Usage{{Usage: netgear.NewNumber(32.46), Unit: 1}}
+	return di
+}
+
+// PortRows returns statistics for the ports 1 to n without any dropped packets
+func PortRows(n int) []netgear.PortStatisticRow {
+	rows := make([]netgear.PortStatisticRow, n)
+	for i := range rows {
+		rows[i] = netgear.PortStatisticRow{
+			Port:         i + 1,
+			InTotalPkts:  netgear.NewNumber(1000),
+			InOctets:     netgear.NewNumber(64000),
+			OutTotalPkts: netgear.NewNumber(1000),
+			OutOctets:    netgear.NewNumber(64000),
+		}
+	}
+	return rows
+}
+
+// DefaultPoeStatus returns two PoE ports, one of them enabled and powering a device
+func DefaultPoeStatus() netgear.PoeStatus {
+	return netgear.PoeStatus{PoePortConfig: []netgear.PoePort{
+		{Port: "1", Enable: true, CurrentPower: 4500, PowerLimit: 30000},
+		{Port: "2", Enable: false, CurrentPower: 0, PowerLimit: 30000},
+	}}
+}
+
+// SetDeviceInfo replaces the response of the device_info endpoint
+func (s *Server) SetDeviceInfo(deviceInfo netgear.DeviceInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deviceInfo = deviceInfo
+}
+
+// SetPortStatistics replaces the rows returned for the given statistics type ("inbound" or "outbound")
+func (s *Server) SetPortStatistics(statType string, rows []netgear.PortStatisticRow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ports[statType] = rows
+}
+
+// SetPoeStatus replaces the response of the swcfg_poe endpoint
+func (s *Server) SetPoeStatus(poeStatus netgear.PoeStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.poe = poeStatus
+}
+
+// InjectFault makes the server respond to requests of endpoint according to fault
+func (s *Server) InjectFault(endpoint string, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[endpoint] = &fault
+}
+
+// ClearFaults removes all injected faults
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.faults)
+}
+
+// ExpireSessions invalidates all sessions, like a session timeout of the switch
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.sessions)
+}
+
+// Sessions returns the number of sessions that are currently open
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Requests returns the method and request URI of all requests received so far, e.g. "GET /api/v1/device_info"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/login", s.withFault(EndpointLogin, s.login))
+	mux.HandleFunc("GET /api/v1/logout", s.withFault(EndpointLogout, s.withSession(s.logout)))
+	mux.HandleFunc("GET /api/v1/device_info", s.withFault(EndpointDeviceInfo, s.withSession(s.serveDeviceInfo)))
+	mux.HandleFunc("GET /api/v1/port_statistics",
+		s.withFault(EndpointPortStatistics, s.withSession(s.servePortStatistics)))
+	mux.HandleFunc("GET /api/v1/swcfg_poe", s.withFault(EndpointPoe, s.withSession(s.servePoe)))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, envelope("failure", 404, "Not found", nil))
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// withFault applies an injected fault before calling next
+func (s *Server) withFault(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		var fault Fault
+		if f, ok := s.faults[endpoint]; ok {
+			fault = *f
+			if f.Times > 0 {
+				f.Times--
+				if f.Times == 0 {
+					delete(s.faults, endpoint)
+				}
+			}
+		}
+		s.mu.Unlock()
+
+		if fault.Delay > 0 {
+			select {
+			case <-time.After(fault.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		switch {
+		case fault.Body != "":
+			status := fault.Status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(fault.Body))
+		case fault.Status != 0:
+			writeJSON(w, fault.Status, envelope("failure", fault.Status, http.StatusText(fault.Status), nil))
+		default:
+			next(w, r)
+		}
+	}
+}
+
+// withSession rejects requests without a valid session token
+func (s *Server) withSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		valid := s.sessions[r.Header.Get("session")]
+		s.mu.Unlock()
+
+		if !valid {
+			writeJSON(w, http.StatusUnauthorized, envelope("failure", 401, "Invalid session", nil))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		User struct {
+			Name     string `json:"name"`
+			Password string `json:"password"`
+		} `json:"user"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, envelope("failure", 400, "Invalid request", nil))
+		return
+	}
+	if payload.User.Name != s.Username || payload.User.Password != s.Password {
+		writeJSON(w, http.StatusUnauthorized, envelope("failure", 401, "Invalid username or password", nil))
+		return
+	}
+
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	session := hex.EncodeToString(token)
+
+	s.mu.Lock()
+	s.sessions[session] = true
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, envelope("success", 0, "Operation success", map[string]any{
+		"user": map[string]any{"name": payload.User.Name, "session": session},
+	}))
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	delete(s.sessions, r.Header.Get("session"))
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, envelope("success", 0, "Operation success", nil))
+}
+
+func (s *Server) serveDeviceInfo(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, envelope("success", 0, "Operation success", map[string]any{
+		"deviceInfo": s.deviceInfo.DeviceInfo,
+	}))
+}
+
+func (s *Server) servePortStatistics(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("indexPage"))
+	pageSize, _ := strconv.Atoi(q.Get("pageSize"))
+	page = max(page, 1)
+	if pageSize < 1 {
+		pageSize = 25
+	}
+
+	s.mu.Lock()
+	rows := s.ports[q.Get("type")]
+	ignorePagination, portQuery := s.IgnorePagination, s.PortQuery
+	s.mu.Unlock()
+
+	if portID, err := strconv.Atoi(q.Get("portId")); err == nil && portQuery {
+		i := slices.IndexFunc(rows, func(row netgear.PortStatisticRow) bool { return row.Port == portID })
+		if i < 0 {
+			rows = nil
+		} else {
+			rows = rows[i : i+1]
+		}
+	} else {
+		if ignorePagination {
+			page = 1
+		}
+		start := min((page-1)*pageSize, len(rows))
+		rows = rows[start:min(start+pageSize, len(rows))]
+	}
+
+	writeJSON(w, http.StatusOK, envelope("success", 0, "Operation success", map[string]any{
+		"portStatistics": map[string]any{"rows": rows},
+	}))
+}
+
+func (s *Server) servePoe(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, envelope("success", 0, "Operation success", map[string]any{
+		"poePortConfig": s.poe.PoePortConfig,
+	}))
+}
+
+// envelope adds the status information the switch sends with every response to data
+func envelope(status string, code int, msg string, data map[string]any) map[string]any {
+	if data == nil {
+		data = make(map[string]any)
+	}
+	data["resp"] = map[string]any{"status": status, "respCode": code, "respMsg": msg}
+	return data
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
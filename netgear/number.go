@@ -0,0 +1,148 @@
+package netgear
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Number is a numeric value reported by the switch. Depending on the firmware, values are sent as JSON numbers or as
+// strings, optionally followed by a unit, e.g. 42, "42", "7.13%" or "3000 RPM".
+//
+// Decoding a Number never fails, so a single unexpected value does not prevent decoding the rest of a response.
+// Instead, Float64 returns an error naming the path of the field the value was decoded from. Missing values and null
+// are decoded as zero.
+type Number struct {
+	value   float64
+	invalid bool
+	raw     string
+	path    string
+}
+
+// NewNumber returns a Number with the given value
+func NewNumber(value float64) Number {
+	return Number{value: value}
+}
+
+// Float64 returns the value or an error if the switch sent a value that could not be parsed
+func (n Number) Float64() (float64, error) {
+	if n.invalid {
+		return 0, &FieldError{Path: n.path, Value: n.raw}
+	}
+	return n.value, nil
+}
+
+// String returns the value as sent by the switch if it could not be parsed, the parsed value otherwise
+func (n Number) String() string {
+	if n.invalid {
+		return n.raw
+	}
+	return strconv.FormatFloat(n.value, 'f', -1, 64)
+}
+
+func (n *Number) UnmarshalJSON(data []byte) error {
+	*n = Number{}
+
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	s := string(data)
+	if data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			s = string(data)
+		}
+	}
+
+	value, err := parseNumber(s)
+	if err != nil {
+		n.invalid = true
+		n.raw = s
+		return nil
+	}
+	n.value = value
+	return nil
+}
+
+func (n Number) MarshalJSON() ([]byte, error) {
+	if n.invalid {
+		return json.Marshal(n.raw)
+	}
+	return json.Marshal(n.value)
+}
+
+// numberRe matches a number optionally followed by one of the units sent by the switch, "%", "C", "°C" or "RPM".
+// Anything else, like thousands separators or a second number, makes the value invalid instead of silently cutting it
+// off.
+var numberRe = regexp.MustCompile(`^([+-]?(?:\d+(?:\.\d*)?|\.\d+)(?:[eE][+-]?\d+)?)\s*(?i:%|°?C|RPM)?$`)
+
+func parseNumber(s string) (float64, error) {
+	m := numberRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return strconv.ParseFloat(m[1], 64)
+}
+
+// FieldError describes a value of a response that could not be parsed
+type FieldError struct {
+	// Path is the location of the value in the JSON response, e.g. "deviceInfo.fan[0].details[1].speed"
+	Path  string
+	Value string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("invalid value %q for field %s", e.Value, e.Path)
+}
+
+var numberType = reflect.TypeFor[Number]()
+
+// annotateNumbers stores the path of every invalid Number in v, so Number.Float64 can report it. v has to be a pointer.
+func annotateNumbers(v any) {
+	annotateValue(reflect.ValueOf(v), "")
+}
+
+func annotateValue(v reflect.Value, path string) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			annotateValue(v.Elem(), path)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			annotateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Struct:
+		if v.Type() == numberType {
+			if !v.CanAddr() {
+				return
+			}
+			if n := v.Addr().Interface().(*Number); n.invalid {
+				n.path = path
+			}
+			return
+		}
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			annotateValue(v.Field(i), name)
+		}
+	}
+}
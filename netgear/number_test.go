@@ -0,0 +1,165 @@
+package netgear
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{"42", 42, false},
+		{"7.13%", 7.13, false},
+		{" 3000 RPM", 3000, false},
+		{"44C", 44, false},
+		{"-5", -5, false},
+		{"+1.5e3", 1500, false},
+		{".5", 0.5, false},
+		{"44 °C", 44, false},
+		{"3000rpm", 3000, false},
+		{"", 0, true},
+		{"N/A", 0, true},
+		{"%", 0, true},
+		{"1,234,567", 0, true},
+		{"0x1F", 0, true},
+		{"12 of 40", 0, true},
+		{"12 34", 0, true},
+		{"1.2.3", 0, true},
+		{"42 W", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseNumber(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNumber(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseNumber(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumberUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    float64
+		wantErr bool
+		wantRaw string
+	}{
+		{"number", `42.5`, 42.5, false, ""},
+		{"string", `"42"`, 42, false, ""},
+		{"percent", `"7.13%"`, 7.13, false, ""},
+		{"unit", `"3000 RPM"`, 3000, false, ""},
+		{"null", `null`, 0, false, ""},
+		{"empty string", `""`, 0, true, ""},
+		{"text", `"N/A"`, 0, true, "N/A"},
+		{"boolean", `true`, 0, true, "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n Number
+			if err := json.Unmarshal([]byte(tt.in), &n); err != nil {
+				t.Fatalf("decoding a Number must not fail: %v", err)
+			}
+
+			got, err := n.Float64()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Float64() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Float64() = %v, want %v", got, tt.want)
+			}
+
+			var fieldErr *FieldError
+			if errors.As(err, &fieldErr) && fieldErr.Value != tt.wantRaw {
+				t.Errorf("FieldError.Value = %q, want %q", fieldErr.Value, tt.wantRaw)
+			}
+		})
+	}
+}
+
+func TestNumberMarshalJSON(t *testing.T) {
+	var n struct {
+		Valid   Number `json:"valid"`
+		Invalid Number `json:"invalid"`
+	}
+	if err := json.Unmarshal([]byte(`{"valid":"7.5%","invalid":"N/A"}`), &n); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"valid":7.5,"invalid":"N/A"}`; string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+	if n.Valid.String() != "7.5" || n.Invalid.String() != "N/A" {
+		t.Errorf("String() = %q, %q, want \"7.5\", \"N/A\"", n.Valid.String(), n.Invalid.String())
+	}
+}
+
+func TestAnnotateNumbers(t *testing.T) {
+	body := `{"deviceInfo":{
+		"fan":[{"details":[{"speed":"2000"},{"speed":"fast"}]}],
+		"sensor":[{"details":[{"temp":"44"},{"temp":"hot"}]}],
+		"cpu":[{"usage":"n/a"}],
+		"memory":[{"usage":"32%"}]
+	}}`
+
+	var di DeviceInfo
+	if err := json.Unmarshal([]byte(body), &di); err != nil {
+		t.Fatal(err)
+	}
+	annotateNumbers(&di)
+
+	tests := []struct {
+		name     string
+		number   Number
+		wantPath string
+	}{
+		{"valid fan", di.DeviceInfo.Fan[0].Details[0].Speed, ""},
+		{"invalid fan", di.DeviceInfo.Fan[0].Details[1].Speed, "deviceInfo.fan[0].details[1].speed"},
+		{"invalid sensor", di.DeviceInfo.Sensor[0].Details[1].Temperature, "deviceInfo.sensor[0].details[1].temp"},
+		{"invalid cpu", di.DeviceInfo.Cpu[0].Usage, "deviceInfo.cpu[0].usage"},
+		{"valid memory", di.DeviceInfo.Memory[0].Usage, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.number.Float64()
+			if tt.wantPath == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("expected *FieldError, got %v", err)
+			}
+			if fieldErr.Path != tt.wantPath {
+				t.Errorf("FieldError.Path = %q, want %q", fieldErr.Path, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestStringPercentToFloat(t *testing.T) {
+	got, err := StringPercentToFloat("7.13%")
+	if err != nil || got != 7.13 {
+		t.Errorf("StringPercentToFloat() = %v, %v, want 7.13", got, err)
+	}
+	if _, err := StringPercentToFloat("n/a"); err == nil {
+		t.Error("expected an error for an invalid percentage")
+	}
+}
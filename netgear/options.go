@@ -0,0 +1,167 @@
+package netgear
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const defaultUserAgent = "check-netgear"
+
+// Option configures a Netgear client, see NewNetgear
+type Option func(*options)
+
+type options struct {
+	httpClient   *http.Client
+	transport    http.RoundTripper
+	tlsConfig    *tls.Config
+	proxy        *url.URL
+	timeout      time.Duration
+	userAgent    string
+	logger       *slog.Logger
+	retryPolicy  RetryPolicy
+	maxParallel  int
+	sessionCache *SessionCache
+	trace        io.Writer
+	recordDir    string
+	replayDir    string
+}
+
+// WithHTTPClient uses a copy of client for all requests. Its transport and timeout are kept unless they are
+// overwritten by other options.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) { o.httpClient = client }
+}
+
+// WithTransport sends all requests using transport, e.g. to use a proxy or an in-process fake of the API
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) { o.transport = transport }
+}
+
+// WithTLSConfig sets the TLS configuration of the transport. It can't be combined with a transport that is not an
+// *http.Transport.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) { o.tlsConfig = config }
+}
+
+// WithProxy sends all requests through the proxy at proxyURL instead of the proxy configured by the environment
+// variables HTTP_PROXY, HTTPS_PROXY and NO_PROXY. Supported schemes are http, https, socks5 and socks5h, credentials
+// for the proxy can be given as user info of the URL. It can't be combined with a transport that is not an
+// *http.Transport.
+func WithProxy(proxyURL *url.URL) Option {
+	return func(o *options) { o.proxy = proxyURL }
+}
+
+// WithTimeout limits the duration of every single request, the default is 10 seconds
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.timeout = timeout }
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) { o.userAgent = userAgent }
+}
+
+// WithLogger logs retries, session renewals and responses that could not be recorded to logger, by default nothing is
+// logged
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithRetryPolicy configures how failed GET requests are repeated, see DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) { o.retryPolicy = policy }
+}
+
+// WithMaxParallel limits the number of requests that are sent to the switch at the same time. Values below 1 remove
+// the limit.
+func WithMaxParallel(maxParallel int) Option {
+	return func(o *options) { o.maxParallel = maxParallel }
+}
+
+// WithSessionCache enables reusing session tokens across plugin runs, see Netgear.Authenticate
+func WithSessionCache(cache *SessionCache) Option {
+	return func(o *options) { o.sessionCache = cache }
+}
+
+// WithTrace logs all requests and responses including their bodies to w. Credentials and session tokens are redacted.
+func WithTrace(w io.Writer) Option {
+	return func(o *options) { o.trace = w }
+}
+
+// WithRecorder stores the body of every successful GET response in dir. Serial numbers, MAC and IP addresses and
+// host names are anonymized, so the files can be attached to bug reports and replayed using WithReplay.
+func WithRecorder(dir string) Option {
+	return func(o *options) { o.recordDir = dir }
+}
+
+// WithReplay answers all requests using the responses recorded in dir by WithRecorder, instead of contacting a
+// switch. Login and logout always succeed, requests without a recorded response are answered with 404.
+func WithReplay(dir string) Option {
+	return func(o *options) { o.replayDir = dir }
+}
+
+// newHTTPClient builds the HTTP client from the options
+func (o *options) newHTTPClient() (*http.Client, error) {
+	client := &http.Client{Timeout: defaultTimeout}
+	if o.httpClient != nil {
+		c := *o.httpClient
+		client = &c
+	}
+	if o.timeout != 0 {
+		client.Timeout = o.timeout
+	}
+	if o.transport != nil {
+		client.Transport = o.transport
+	}
+
+	if client.Transport == nil {
+		// the default transport uses the proxy configured by the environment
+		client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	} else if o.tlsConfig != nil || o.proxy != nil {
+		t, ok := client.Transport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("TLS and proxy settings can only be used with an *http.Transport, got %T",
+				client.Transport)
+		}
+		// don't modify a transport that may be shared with the caller
+		client.Transport = t.Clone()
+	}
+
+	if o.tlsConfig != nil {
+		client.Transport.(*http.Transport).TLSClientConfig = o.tlsConfig
+	}
+
+	if o.proxy != nil {
+		switch o.proxy.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q (use http, https, socks5 or socks5h)", o.proxy.Scheme)
+		}
+		if o.proxy.Host == "" {
+			return nil, fmt.Errorf("proxy URL %q has no host", o.proxy.Redacted())
+		}
+		client.Transport.(*http.Transport).Proxy = http.ProxyURL(o.proxy)
+	}
+
+	if o.replayDir != "" {
+		client.Transport = &replayTransport{dir: o.replayDir}
+	}
+	if o.recordDir != "" {
+		if err := os.MkdirAll(o.recordDir, 0o700); err != nil {
+			return nil, fmt.Errorf("error creating record directory: %w", err)
+		}
+		client.Transport = &recordTransport{next: client.Transport, dir: o.recordDir, logger: o.logger}
+	}
+
+	if o.trace != nil {
+		client.Transport = &traceTransport{next: client.Transport, out: o.trace}
+	}
+
+	return client, nil
+}
@@ -0,0 +1,87 @@
+package netgear
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+const (
+	portStatisticsPageSize = 25
+	// portStatisticsMaxPages guards against firmware that keeps returning full pages of new ports
+	portStatisticsMaxPages = 16
+)
+
+// PortStatistics returns the traffic statistics of the given type ("inbound" or "outbound") for all ports of the
+// switch.
+//
+// Some firmware releases ignore the parameters "indexPage" and "pageSize" and always return the first page, which
+// limits the result to 25 ports. This is detected by comparing the pages. In that case, every port of wantPorts that
+// is not part of the first page is queried on its own using the parameter "portId". This parameter is speculative, it
+// is neither documented nor known to be supported by any firmware. If the switch ignores it as well, it returns the
+// first page again and the remaining ports are not queried. Ports of wantPorts the switch returned no statistics for
+// are not part of the result.
+func (n *Netgear) PortStatistics(ctx context.Context, statType string, wantPorts []int) (*PortStatistics, error) {
+	var stats PortStatistics
+	seen := make(map[int]bool)
+	add := func(rows []PortStatisticRow) (added int) {
+		for _, row := range rows {
+			if !seen[row.Port] {
+				seen[row.Port] = true
+				stats.PortStatistics.Rows = append(stats.PortStatistics.Rows, row)
+				added++
+			}
+		}
+		return added
+	}
+
+	for page := 1; page <= portStatisticsMaxPages; page++ {
+		rows, err := n.portStatisticsPage(ctx, statType, page, nil)
+		if err != nil {
+			return nil, err
+		}
+		// a page without new ports means that the end was reached or that pagination is not supported
+		if add(rows) == 0 || len(rows) < portStatisticsPageSize {
+			break
+		}
+	}
+
+	for _, port := range wantPorts {
+		if seen[port] {
+			continue
+		}
+		rows, err := n.portStatisticsPage(ctx, statType, 1, &port)
+		if err != nil {
+			return nil, err
+		}
+		add(rows)
+		if len(rows) > 0 && !seen[port] {
+			// other ports instead of the requested one, the firmware ignores "portId", no need to ask for the others.
+			// No rows at all just mean that the port doesn't exist.
+			break
+		}
+	}
+
+	slices.SortFunc(stats.PortStatistics.Rows, func(a, b PortStatisticRow) int { return a.Port - b.Port })
+	return &stats, nil
+}
+
+// portStatisticsPage requests a single page of port statistics, optionally limited to a single port
+func (n *Netgear) portStatisticsPage(ctx context.Context, statType string, page int, port *int) ([]PortStatisticRow, error) {
+	u := n.baseUrl.JoinPath("port_statistics")
+	q := u.Query()
+	q.Set("type", statType)
+	q.Set("indexPage", strconv.Itoa(page))
+	q.Set("pageSize", strconv.Itoa(portStatisticsPageSize))
+	if port != nil {
+		q.Set("portId", strconv.Itoa(*port))
+	}
+	u.RawQuery = q.Encode()
+
+	var stats PortStatistics
+	if err := n.doRequestURL(ctx, http.MethodGet, u, &stats); err != nil {
+		return nil, err
+	}
+	return stats.PortStatistics.Rows, nil
+}
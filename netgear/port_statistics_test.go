@@ -0,0 +1,126 @@
+package netgear_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/icinga/check-netgear/netgear/netgeartest"
+)
+
+func TestPortStatistics(t *testing.T) {
+	tests := []struct {
+		name             string
+		ports            int
+		ignorePagination bool
+		portQuery        bool
+		wantPorts        []int
+		want             []int
+		wantRequests     int
+	}{
+		{
+			name:         "single page",
+			ports:        8,
+			wantPorts:    []int{1, 8},
+			want:         []int{1, 2, 3, 4, 5, 6, 7, 8},
+			wantRequests: 1,
+		},
+		{
+			name:         "pagination",
+			ports:        52,
+			wantPorts:    []int{1, 52},
+			want:         seq(1, 52),
+			wantRequests: 3,
+		},
+		{
+			name:         "full last page",
+			ports:        50,
+			want:         seq(1, 50),
+			wantRequests: 3,
+		},
+		{
+			name:             "pagination ignored",
+			ports:            30,
+			ignorePagination: true,
+			wantPorts:        []int{1, 28},
+			want:             seq(1, 25),
+			// the repeated first page ends the pagination, the single query for port 28 returns the first page again
+			wantRequests: 3,
+		},
+		{
+			name:             "pagination ignored, ports queried by portId",
+			ports:            30,
+			ignorePagination: true,
+			portQuery:        true,
+			wantPorts:        []int{1, 26, 30},
+			want:             append(seq(1, 25), 26, 30),
+			wantRequests:     4,
+		},
+		{
+			name:             "pagination and portId ignored",
+			ports:            30,
+			ignorePagination: true,
+			wantPorts:        []int{28, 29, 30},
+			want:             seq(1, 25),
+			// the first page returned for port 28 shows that portId is ignored, so 29 and 30 are not queried
+			wantRequests: 3,
+		},
+		{
+			name:             "unknown port doesn't stop the portId fallback",
+			ports:            30,
+			ignorePagination: true,
+			portQuery:        true,
+			wantPorts:        []int{40, 26},
+			want:             append(seq(1, 25), 26),
+			wantRequests:     4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := netgeartest.NewServer()
+			defer srv.Close()
+			srv.IgnorePagination = tt.ignorePagination
+			srv.PortQuery = tt.portQuery
+			srv.SetPortStatistics("inbound", netgeartest.PortRows(tt.ports))
+
+			n := newClient(t, srv)
+			ctx := context.Background()
+			if err := n.Login(ctx); err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+
+			stats, err := n.PortStatistics(ctx, "inbound", tt.wantPorts)
+			if err != nil {
+				t.Fatalf("PortStatistics: %v", err)
+			}
+
+			var got []int
+			for _, row := range stats.PortStatistics.Rows {
+				got = append(got, row.Port)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got ports %v, want %v", got, tt.want)
+			}
+
+			requests := 0
+			for _, r := range srv.Requests() {
+				if r != "POST /api/v1/login" {
+					requests++
+				}
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("got %d requests, want %d: %v", requests, tt.wantRequests, srv.Requests())
+			}
+		})
+	}
+}
+
+// seq returns the numbers from first to last
+func seq(first, last int) []int {
+	var s []int
+	for i := first; i <= last; i++ {
+		s = append(s, i)
+	}
+	return s
+}
@@ -0,0 +1,240 @@
+package netgear
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// recordTransport stores the anonymized body of every successful GET response in a directory, so it can be replayed
+// by replayTransport later on. Responses that can't be recorded are logged and passed through unchanged, so recording
+// never fails a check.
+type recordTransport struct {
+	next   http.RoundTripper
+	dir    string
+	logger *slog.Logger
+
+	anonymizer anonymizer
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, err
+	}
+	if path.Base(req.URL.Path) == "logout" {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	anonymized, err := t.anonymizer.anonymize(body)
+	if err != nil {
+		t.logger.Warn("response not recorded, anonymizing failed", "path", req.URL.Path, "error", err)
+		return resp, nil
+	}
+	if err := os.WriteFile(filepath.Join(t.dir, fixtureName(req)), anonymized, 0o600); err != nil {
+		t.logger.Warn("response not recorded", "path", req.URL.Path, "error", err)
+	}
+
+	return resp, nil
+}
+
+// replayTransport answers requests using the responses stored by recordTransport instead of contacting a switch
+type replayTransport struct {
+	dir string
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+
+	switch path.Base(req.URL.Path) {
+	case "login":
+		return replayResponse(req, http.StatusOK,
+			`{"user":{"session":"replay"},"resp":{"status":"success","respCode":0,"respMsg":"Operation success"}}`), nil
+	case "logout":
+		return replayResponse(req, http.StatusOK,
+			`{"resp":{"status":"success","respCode":0,"respMsg":"Operation success"}}`), nil
+	}
+
+	for _, name := range replayNames(req) {
+		body, err := os.ReadFile(filepath.Join(t.dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error replaying response: %w", err)
+		}
+		return replayResponse(req, http.StatusOK, string(body)), nil
+	}
+
+	return replayResponse(req, http.StatusNotFound,
+		`{"resp":{"status":"failure","respCode":404,"respMsg":"No recorded response"}}`), nil
+}
+
+// replayNames returns the file names that are tried for req in this order: the name used by recordTransport, the
+// endpoint followed by the statistic type, e.g. "port_statistics_inbound.json", and the endpoint, e.g.
+// "device_info.json". This allows replaying responses that were saved manually without paging.
+func replayNames(req *http.Request) []string {
+	endpoint := path.Base(req.URL.Path)
+	names := []string{fixtureName(req)}
+	if statType := req.URL.Query().Get("type"); statType != "" {
+		names = append(names, fixtureNameRe.ReplaceAllString(endpoint+"_"+statType, "-")+".json")
+	}
+	return append(names, endpoint+".json")
+}
+
+func replayResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+var fixtureNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fixtureName returns the file name of the recorded response of req, built from the endpoint and the query
+// parameters, e.g. "port_statistics_indexPage-1_pageSize-25_type-inbound.json"
+func fixtureName(req *http.Request) string {
+	parts := []string{path.Base(req.URL.Path)}
+	query := req.URL.Query()
+	for _, key := range slices.Sorted(maps.Keys(query)) {
+		parts = append(parts, key+"-"+strings.Join(query[key], ","))
+	}
+	return fixtureNameRe.ReplaceAllString(strings.Join(parts, "_"), "-") + ".json"
+}
+
+var (
+	macRe  = regexp.MustCompile(`(?i)\b[0-9a-f]{2}(?:[:-][0-9a-f]{2}){5}\b`)
+	ipv4Re = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Re = regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}`)
+)
+
+// versionKeys are the lower case keys of version numbers, which are kept although they look like IP addresses
+var versionKeys = []string{"swver", "fwversion", "bootversion", "hwversion"}
+
+// anonymizer replaces serial numbers, MAC addresses, IP addresses and host names in responses. The same value is
+// always replaced by the same placeholder, so relations between values are kept.
+type anonymizer struct {
+	mu           sync.Mutex
+	replacements map[string]string
+	counters     map[string]int
+}
+
+func (a *anonymizer) anonymize(body []byte) ([]byte, error) {
+	var data any
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	data = a.value("", data)
+	a.mu.Unlock()
+
+	return json.MarshalIndent(data, "", "  ")
+}
+
+func (a *anonymizer) value(key string, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		// sorted, so the placeholders of a response are the same for every recording
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			v[k] = a.value(k, v[k])
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = a.value(key, child)
+		}
+		return v
+	case string:
+		return a.string(key, v)
+	default:
+		return v
+	}
+}
+
+func (a *anonymizer) string(key, s string) string {
+	lower := strings.ToLower(key)
+	switch {
+	case s == "":
+		return s
+	case strings.Contains(lower, "serial"):
+		return a.replace("serial", s, "SERIAL%04d")
+	case strings.Contains(lower, "host") || strings.HasSuffix(lower, "name") && !strings.Contains(lower, "model"):
+		// covers e.g. "name", "hostName", "sysName", "deviceName" and "systemName", the model is needed to reproduce
+		// model specific issues
+		return a.replace("host", s, "switch-%d")
+	case slices.Contains(versionKeys, lower):
+		// versions like 13.0.4.26 look like IP addresses, but are needed to reproduce firmware specific issues
+		return s
+	}
+
+	s = macRe.ReplaceAllStringFunc(s, func(mac string) string {
+		// the same address may be written with colons or dashes
+		return a.replace("mac", strings.ReplaceAll(strings.ToLower(mac), "-", ":"), "02:00:00:00:%02x:%02x")
+	})
+	s = ipv4Re.ReplaceAllStringFunc(s, func(ip string) string {
+		return a.replace("ipv4", ip, "192.0.2.%d")
+	})
+	// the IPv6 pattern also matches times like 12:30:45, so it is only applied to address fields
+	if strings.Contains(lower, "ip") || strings.Contains(lower, "addr") {
+		s = ipv6Re.ReplaceAllStringFunc(s, func(ip string) string {
+			// MAC addresses, including the placeholders of the replaced ones, match the IPv6 pattern as well
+			if macRe.FindString(ip) == ip {
+				return ip
+			}
+			return a.replace("ipv6", strings.ToLower(ip), "2001:db8::%x")
+		})
+	}
+	return s
+}
+
+// replace returns the placeholder for value, creating a new one from format for unknown values
+func (a *anonymizer) replace(kind, value, format string) string {
+	if a.replacements == nil {
+		a.replacements = make(map[string]string)
+		a.counters = make(map[string]int)
+	}
+
+	key := kind + "\x00" + value
+	if r, ok := a.replacements[key]; ok {
+		return r
+	}
+
+	a.counters[kind]++
+	n := a.counters[kind]
+	var r string
+	if kind == "mac" {
+		r = fmt.Sprintf(format, n>>8&0xff, n&0xff)
+	} else {
+		r = fmt.Sprintf(format, n)
+	}
+	a.replacements[key] = r
+	return r
+}
@@ -0,0 +1,147 @@
+package netgear
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAnonymize(t *testing.T) {
+	body := `{"deviceInfo":{
+		"details":[{"serialNumber":"6LB1234567890","name":"core-sw-01","macAddr":"A0:40:A0:12:34:56",
+			"ipAddr":"10.1.2.3","ipv6Addr":"fe80::a240:a0ff:fe12:3456","swVer":"13.0.4.26","upTime":"1 days, 12:30:45",
+			"ntpServer":"10.1.2.4","serverIp":"10.0.0.5","dnsServer":"10.1.2.3","fwVersion":"13.0.4.26",
+			"bootVersion":"B1.0.0.9","hwVersion":"1.0.0.2","driverVersion":"10.1.2.9",
+			"deviceName":"core-sw-01","systemName":"core-sw-02","modelName":"M4250-10G2F-PoE+"}],
+		"neighbors":[{"serialNumber":"6LB1234567890","mac":"a0-40-a0-12-34-56","desc":"uplink to 10.1.2.3"},
+			{"serialNumber":"6LB0000000001","mac":"a0:40:a0:ff:ff:ff","desc":"uplink to 10.9.9.9"}]
+	}}`
+
+	var a anonymizer
+	out, err := a.anonymize([]byte(body))
+	if err != nil {
+		t.Fatalf("anonymize: %v", err)
+	}
+
+	for _, secret := range []string{
+		"6LB", "core-sw-01", "core-sw-02", "A0:40", "a0:40", "a0-40", "10.1.2.3", "10.1.2.4", "10.0.0.5", "10.1.2.9",
+		"10.9.9.9", "fe80::",
+	} {
+		if strings.Contains(string(out), secret) {
+			t.Errorf("anonymized response contains %q:\n%s", secret, out)
+		}
+	}
+
+	var data struct {
+		DeviceInfo struct {
+			Details   []map[string]string `json:"details"`
+			Neighbors []map[string]string `json:"neighbors"`
+		} `json:"deviceInfo"`
+	}
+	if err := json.Unmarshal(out, &data); err != nil {
+		t.Fatalf("anonymized response is not valid JSON: %v", err)
+	}
+	details, neighbors := data.DeviceInfo.Details[0], data.DeviceInfo.Neighbors
+
+	tests := []struct {
+		name, got, want string
+	}{
+		{"serial", details["serialNumber"], "SERIAL0001"},
+		{"same serial", neighbors[0]["serialNumber"], "SERIAL0001"},
+		{"other serial", neighbors[1]["serialNumber"], "SERIAL0002"},
+		{"host name", details["name"], "switch-1"},
+		{"MAC", details["macAddr"], "02:00:00:00:00:01"},
+		{"same MAC in other notation", neighbors[0]["mac"], "02:00:00:00:00:01"},
+		{"other MAC", neighbors[1]["mac"], "02:00:00:00:00:02"},
+		{"IPv4", details["ipAddr"], "192.0.2.1"},
+		{"IPv4 in text", neighbors[0]["desc"], "uplink to 192.0.2.1"},
+		{"other IPv4 in text", neighbors[1]["desc"], "uplink to 192.0.2.5"},
+		{"IPv6", details["ipv6Addr"], "2001:db8::1"},
+		{"NTP server", details["ntpServer"], "192.0.2.3"},
+		{"server IP", details["serverIp"], "192.0.2.4"},
+		{"DNS server", details["dnsServer"], "192.0.2.1"},
+		{"driver version", details["driverVersion"], "192.0.2.2"},
+		{"device name", details["deviceName"], "switch-1"},
+		{"system name", details["systemName"], "switch-2"},
+		{"model", details["modelName"], "M4250-10G2F-PoE+"},
+		{"version", details["swVer"], "13.0.4.26"},
+		{"firmware version", details["fwVersion"], "13.0.4.26"},
+		{"boot version", details["bootVersion"], "B1.0.0.9"},
+		{"hardware version", details["hwVersion"], "1.0.0.2"},
+		{"time", details["upTime"], "1 days, 12:30:45"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+// roundTripFunc is an http.RoundTripper answering requests with a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRecordTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantFile  bool
+		wantError bool
+	}{
+		{"JSON", `{"deviceInfo":{"details":[{"serialNumber":"6LB1234567890"}]}}`, true, false},
+		{"not valid JSON", `<html>maintenance</html>`, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var logs bytes.Buffer
+			transport := &recordTransport{
+				next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					return replayResponse(req, http.StatusOK, tt.body), nil
+				}),
+				dir:    dir,
+				logger: slog.New(slog.NewTextHandler(&logs, nil)),
+			}
+
+			req, err := http.NewRequest(http.MethodGet, "http://switch/api/v1/device_info", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("recording must not fail the request: %v", err)
+			}
+
+			// the original response is passed through
+			body, err := io.ReadAll(resp.Body)
+			if err != nil || string(body) != tt.body {
+				t.Errorf("got body %q, %v, want %q", body, err, tt.body)
+			}
+
+			recorded, err := os.ReadFile(dir + "/device_info.json")
+			if tt.wantFile {
+				if err != nil || strings.Contains(string(recorded), "6LB1234567890") {
+					t.Errorf("expected an anonymized recording, got %q, %v", recorded, err)
+				}
+			} else if err == nil {
+				t.Errorf("expected no recording, got %q", recorded)
+			}
+
+			if logged := strings.Contains(logs.String(), "response not recorded"); logged != tt.wantError {
+				t.Errorf("logged %q, want error logged: %v", logs.String(), tt.wantError)
+			}
+		})
+	}
+}
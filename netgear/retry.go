@@ -0,0 +1,110 @@
+package netgear
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy defines how often and when failed GET requests are repeated. Login is never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts per request, values below 2 disable retries
+	MaxAttempts int
+	// Wait is the base delay before the first retry, it doubles with every further attempt
+	Wait time.Duration
+	// MaxWait caps the delay between two attempts, zero means no cap
+	MaxWait time.Duration
+	// RetryableStatus lists the HTTP status codes that cause a retry
+	RetryableStatus []int
+	// RetryableErrors lists transport errors that cause a retry, matched using errors.Is
+	RetryableErrors []error
+}
+
+// DefaultRetryPolicy returns a policy with retries disabled, but the common transient errors of the embedded web
+// server configured, so only MaxAttempts has to be raised
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 1,
+		Wait:        500 * time.Millisecond,
+		MaxWait:     5 * time.Second,
+		RetryableStatus: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryableErrors: []error{
+			syscall.ECONNRESET,
+			syscall.ECONNREFUSED,
+			syscall.ECONNABORTED,
+			io.EOF,
+			io.ErrUnexpectedEOF,
+		},
+	}
+}
+
+// retryable reports whether err is a transient error according to the policy
+func (p RetryPolicy) retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return slices.Contains(p.RetryableStatus, apiErr.StatusCode)
+	}
+
+	for _, target := range p.RetryableErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// backoff returns the delay before the given retry, growing exponentially with random jitter
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	wait := p.Wait << (retry - 1)
+	if wait <= 0 || (p.MaxWait > 0 && wait > p.MaxWait) {
+		wait = p.MaxWait
+	}
+	if wait <= 0 {
+		return 0
+	}
+	// use a random delay between half and the full backoff, so parallel runs don't retry in lockstep
+	return wait/2 + rand.N(wait/2+1)
+}
+
+// sendWithRetry performs the request using send and repeats GET requests according to the retry policy. Retries are
+// only started if they can finish before the deadline of ctx.
+func (n *Netgear) sendWithRetry(ctx context.Context, method string, send func() error) error {
+	maxAttempts := 1
+	if method == http.MethodGet {
+		maxAttempts = max(1, n.retryPolicy.MaxAttempts)
+	}
+
+	for attempt := 1; ; attempt++ {
+		n.attempts.Add(1)
+		err := send()
+		if err == nil || attempt >= maxAttempts || ctx.Err() != nil || !n.retryPolicy.retryable(err) {
+			return err
+		}
+
+		wait := n.retryPolicy.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		n.logger.Debug("retrying request", "attempt", attempt+1, "wait", wait, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package netgear
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{Wait: 100 * time.Millisecond, MaxWait: time.Second}
+
+	tests := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		// the shift overflows, the cap still applies
+		{70, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.retry), func(t *testing.T) {
+			for range 100 {
+				got := p.backoff(tt.retry)
+				if got < tt.want/2 || got > tt.want {
+					t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.retry, got, tt.want/2, tt.want)
+				}
+			}
+		})
+	}
+
+	if got := (RetryPolicy{}).backoff(1); got != 0 {
+		t.Errorf("backoff without wait = %v, want 0", got)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	p := DefaultRetryPolicy()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"service unavailable", &APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"rate limited", &APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"not found", &APIError{StatusCode: http.StatusNotFound}, false},
+		{"unauthorized", &APIError{StatusCode: http.StatusUnauthorized}, false},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"unexpected EOF", io.ErrUnexpectedEOF, true},
+		{"other", errors.New("invalid response"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.retryable(tt.err); got != tt.want {
+				t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendWithRetry(t *testing.T) {
+	transient := &APIError{StatusCode: http.StatusServiceUnavailable}
+
+	tests := []struct {
+		name         string
+		method       string
+		timeout      time.Duration
+		failures     int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"success", http.MethodGet, 0, 0, 1, false},
+		{"retried until success", http.MethodGet, 0, 2, 3, false},
+		{"attempts exhausted", http.MethodGet, 0, 5, 3, true},
+		{"POST is not retried", http.MethodPost, 0, 1, 1, true},
+		{"no retry after the deadline", http.MethodGet, 5 * time.Millisecond, 5, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &Netgear{
+				logger:      slog.New(slog.DiscardHandler),
+				retryPolicy: RetryPolicy{MaxAttempts: 3, Wait: 20 * time.Millisecond, RetryableStatus: []int{503}},
+			}
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			attempts := 0
+			err := n.sendWithRetry(ctx, tt.method, func() error {
+				attempts++
+				if attempts <= tt.failures {
+					return transient
+				}
+				return nil
+			})
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error %v", err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", attempts, tt.wantAttempts)
+			}
+			// the API requests are counted by doRequestURL
+			if requests, total := n.Stats(); requests != 0 || total != int64(tt.wantAttempts) {
+				t.Errorf("Stats() = %d, %d, want 0, %d", requests, total, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package netgear
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	lockRetryInterval = 50 * time.Millisecond
+	// staleLockAge is the age after which a lock file is considered left over by a crashed run and removed
+	staleLockAge = time.Minute
+)
+
+// SessionCache persists session tokens on disk, so that consecutive plugin runs against the same switch can reuse a
+// session instead of logging in every time
+type SessionCache struct {
+	dir string
+	ttl time.Duration
+}
+
+type cachedSession struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewSessionCache creates a SessionCache storing its files in dir. Cached tokens are considered expired after ttl.
+func NewSessionCache(dir string, ttl time.Duration) (*SessionCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating session cache directory: %w", err)
+	}
+	return &SessionCache{dir: dir, ttl: ttl}, nil
+}
+
+// path returns the cache file for the given base URL and user without exposing either in the file name
+func (c *SessionCache) path(baseUrl, username string) string {
+	sum := sha256.Sum256([]byte(baseUrl + "\x00" + username))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// expired reports whether the token should no longer be used
+func (s cachedSession) expired() bool {
+	return time.Now().After(s.Expires)
+}
+
+// load returns the cached session, which may have expired already
+func (c *SessionCache) load(baseUrl, username string) (cachedSession, bool) {
+	content, err := os.ReadFile(c.path(baseUrl, username))
+	if err != nil {
+		return cachedSession{}, false
+	}
+
+	var session cachedSession
+	if err := json.Unmarshal(content, &session); err != nil || session.Token == "" {
+		return cachedSession{}, false
+	}
+	return session, true
+}
+
+// store writes the token to the cache, expiring after the TTL of the cache. Storing a token again extends its
+// lifetime. The file is replaced atomically, so concurrent readers never see partial data.
+func (c *SessionCache) store(baseUrl, username, token string) error {
+	content, err := json.Marshal(cachedSession{Token: token, Expires: time.Now().Add(c.ttl)})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(baseUrl, username))
+}
+
+// remove deletes the cached token
+func (c *SessionCache) remove(baseUrl, username string) error {
+	err := os.Remove(c.path(baseUrl, username))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// lock acquires an exclusive lock for the given base URL and user, waiting for parallel runs to release it until ctx
+// is done. The returned function releases the lock.
+func (c *SessionCache) lock(ctx context.Context, baseUrl, username string) (func(), error) {
+	lockPath := c.path(baseUrl, username) + ".lock"
+
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("error locking session cache: %w", err)
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			if err := removeStaleLock(lockPath); err != nil {
+				return nil, fmt.Errorf("error removing stale session cache lock: %w", err)
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error locking session cache: %w", ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// removeStaleLock removes the lock file at lockPath if it is still stale. Parallel runs may have noticed the same stale
+// lock, and one of them may have removed it and created a fresh lock in the meantime. So the lock file is renamed
+// first, which only one run can do, and its age is checked again afterwards. A fresh lock of another run is put back.
+func removeStaleLock(lockPath string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(lockPath), ".stale-*")
+	if err != nil {
+		return err
+	}
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := os.Rename(lockPath, tmp.Name()); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// already removed by a parallel run
+			return nil
+		}
+		return err
+	}
+
+	info, err := os.Stat(tmp.Name())
+	if err != nil || time.Since(info.ModTime()) > staleLockAge {
+		return err
+	}
+
+	// a link doesn't replace the lock file if yet another run created one in the meantime
+	if err := os.Link(tmp.Name(), lockPath); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	return nil
+}
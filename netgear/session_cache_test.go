@@ -0,0 +1,131 @@
+package netgear
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T) *SessionCache {
+	t.Helper()
+
+	c, err := NewSessionCache(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatalf("NewSessionCache: %v", err)
+	}
+	return c
+}
+
+// createStaleLock creates a lock file that looks like it was left over by a crashed run
+func createStaleLock(t *testing.T, c *SessionCache) {
+	t.Helper()
+
+	lockPath := c.path("http://switch/api/v1", "admin") + ".lock"
+	if err := os.WriteFile(lockPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionCacheLockWaits(t *testing.T) {
+	c := newTestCache(t)
+
+	unlock, err := c.lock(context.Background(), "http://switch/api/v1", "admin")
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockRetryInterval)
+	defer cancel()
+	if _, err := c.lock(ctx, "http://switch/api/v1", "admin"); err == nil {
+		t.Fatal("expected the second lock to fail while the first one is held")
+	}
+
+	// other users are locked independently
+	unlockOther, err := c.lock(context.Background(), "http://switch/api/v1", "other")
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	unlockOther()
+}
+
+func TestSessionCacheStaleLock(t *testing.T) {
+	c := newTestCache(t)
+	createStaleLock(t, c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock, err := c.lock(ctx, "http://switch/api/v1", "admin")
+	if err != nil {
+		t.Fatalf("expected the stale lock to be taken over: %v", err)
+	}
+	unlock()
+}
+
+func TestSessionCacheStaleLockParallel(t *testing.T) {
+	c := newTestCache(t)
+
+	for range 5 {
+		createStaleLock(t, c)
+
+		var holders, maxHolders atomic.Int32
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Go(func() {
+				unlock, err := c.lock(context.Background(), "http://switch/api/v1", "admin")
+				if err != nil {
+					t.Errorf("lock: %v", err)
+					return
+				}
+				held := holders.Add(1)
+				for {
+					current := maxHolders.Load()
+					if held <= current || maxHolders.CompareAndSwap(current, held) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				holders.Add(-1)
+				unlock()
+			})
+		}
+		wg.Wait()
+
+		if maxHolders.Load() > 1 {
+			t.Fatalf("the lock was held by %d runs at the same time", maxHolders.Load())
+		}
+	}
+}
+
+func TestSessionCacheLoad(t *testing.T) {
+	c := newTestCache(t)
+
+	if _, ok := c.load("http://switch/api/v1", "admin"); ok {
+		t.Fatal("expected no cached session")
+	}
+
+	if err := c.store("http://switch/api/v1", "admin", "token"); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	session, ok := c.load("http://switch/api/v1", "admin")
+	if !ok || session.Token != "token" || session.expired() {
+		t.Fatalf("unexpected cached session %+v", session)
+	}
+	if _, ok := c.load("http://switch/api/v1", "other"); ok {
+		t.Fatal("expected no cached session for another user")
+	}
+
+	if err := c.remove("http://switch/api/v1", "admin"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, ok := c.load("http://switch/api/v1", "admin"); ok {
+		t.Fatal("expected the cached session to be removed")
+	}
+}
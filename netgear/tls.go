@@ -0,0 +1,67 @@
+package netgear
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSOptions contains the TLS settings used to connect to the management API of the switch
+type TLSOptions struct {
+	CAFile             string
+	ClientCert         string
+	ClientKey          string
+	InsecureSkipVerify bool
+	MinVersion         string
+	ServerName         string
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfig builds a tls.Config from the given options
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: opts.InsecureSkipVerify, //nolint:gosec // explicitly requested by the user
+		ServerName:         opts.ServerName,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if opts.MinVersion != "" {
+		version, ok := tlsVersions[opts.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %q (use one of 1.0, 1.1, 1.2, 1.3)", opts.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in CA file %s", opts.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return nil, fmt.Errorf("both a client certificate and a client key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
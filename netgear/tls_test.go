@@ -0,0 +1,95 @@
+package netgear_test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/icinga/check-netgear/netgear"
+	"github.com/icinga/check-netgear/netgear/netgeartest"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	invalidPEM := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(invalidPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    netgear.TLSOptions
+		wantErr string
+	}{
+		{name: "defaults"},
+		{name: "minimum version", opts: netgear.TLSOptions{MinVersion: "1.3"}},
+		{name: "invalid minimum version", opts: netgear.TLSOptions{MinVersion: "1.4"}, wantErr: "unsupported TLS version"},
+		{name: "certificate without key", opts: netgear.TLSOptions{ClientCert: invalidPEM}, wantErr: "both a client certificate and a client key"},
+		{name: "key without certificate", opts: netgear.TLSOptions{ClientKey: invalidPEM}, wantErr: "both a client certificate and a client key"},
+		{name: "invalid key pair", opts: netgear.TLSOptions{ClientCert: invalidPEM, ClientKey: invalidPEM}, wantErr: "error loading client certificate"},
+		{name: "missing CA file", opts: netgear.TLSOptions{CAFile: filepath.Join(dir, "missing.pem")}, wantErr: "error reading CA file"},
+		{name: "CA file without certificates", opts: netgear.TLSOptions{CAFile: invalidPEM}, wantErr: "no valid certificates"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := netgear.NewTLSConfig(tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.opts.MinVersion == "" && config.MinVersion != tls.VersionTLS12 {
+				t.Errorf("expected TLS 1.2 as default minimum version, got %x", config.MinVersion)
+			}
+			if tt.opts.MinVersion == "1.3" && config.MinVersion != tls.VersionTLS13 {
+				t.Errorf("expected TLS 1.3 as minimum version, got %x", config.MinVersion)
+			}
+		})
+	}
+}
+
+func TestTLSHandshake(t *testing.T) {
+	srv := netgeartest.NewTLSServer()
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    netgear.TLSOptions
+		wantErr bool
+	}{
+		// the certificate of the test server is self-signed
+		{"verified", netgear.TLSOptions{}, true},
+		{"insecure skip verify", netgear.TLSOptions{InsecureSkipVerify: true}, false},
+		{"CA file", netgear.TLSOptions{CAFile: caFile}, false},
+		{"CA file with another server name", netgear.TLSOptions{CAFile: caFile, ServerName: "switch.invalid"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := netgear.NewTLSConfig(tt.opts)
+			if err != nil {
+				t.Fatalf("NewTLSConfig: %v", err)
+			}
+
+			err = newClient(t, srv, netgear.WithTLSConfig(config)).Login(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Login: unexpected error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package netgear
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redacted = "<redacted>"
+
+// redactedKeys are JSON keys whose values are never written to a trace
+var redactedKeys = []string{"password", "session"}
+
+// traceTransport logs every request and response passing through it. Credentials and session tokens are redacted, so
+// the trace can be shared.
+type traceTransport struct {
+	next http.RoundTripper
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	start := time.Now()
+	resp, err := next.RoundTrip(req)
+	latency := time.Since(start).Round(time.Millisecond)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--> %s %s\n", req.Method, req.URL.Redacted())
+	for _, name := range slices.Sorted(maps.Keys(req.Header)) {
+		value := strings.Join(req.Header[name], ", ")
+		if strings.EqualFold(name, "session") || strings.EqualFold(name, "authorization") {
+			value = redacted
+		}
+		fmt.Fprintf(&sb, "    %s: %s\n", name, value)
+	}
+	if len(reqBody) > 0 {
+		fmt.Fprintf(&sb, "    %s\n", redactBody(reqBody))
+	}
+
+	if err != nil {
+		fmt.Fprintf(&sb, "<-- error after %v: %v\n", latency, err)
+		t.write(sb.String())
+		return nil, err
+	}
+
+	respBody, readErr := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	fmt.Fprintf(&sb, "<-- %s (%v)\n", resp.Status, latency)
+	if readErr != nil {
+		fmt.Fprintf(&sb, "    error reading body: %v\n", readErr)
+	} else if len(respBody) > 0 {
+		fmt.Fprintf(&sb, "    %s\n", redactBody(respBody))
+	}
+	t.write(sb.String())
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return resp, nil
+}
+
+func (t *traceTransport) write(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = io.WriteString(t.out, s)
+}
+
+// redactBody replaces the values of all redactedKeys in a JSON body. Bodies that are not valid JSON are replaced
+// completely if they contain one of the keys, as they can't be redacted reliably.
+func redactBody(body []byte) string {
+	var data any
+	if err := json.Unmarshal(body, &data); err != nil {
+		for _, key := range redactedKeys {
+			if bytes.Contains(bytes.ToLower(body), []byte(key)) {
+				return fmt.Sprintf("%s (%d bytes, not valid JSON)", redacted, len(body))
+			}
+		}
+		return string(body)
+	}
+
+	redactValue(data)
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(data); err != nil {
+		return redacted
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+func redactValue(value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			for _, r := range redactedKeys {
+				if strings.EqualFold(key, r) {
+					v[key] = redacted
+				}
+			}
+			redactValue(child)
+		}
+	case []any:
+		for _, child := range v {
+			redactValue(child)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package netgear
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		want       string
+		wantHidden []string
+	}{
+		{
+			name:       "login request",
+			body:       `{"user":{"name":"admin","password":"s3cret"}}`,
+			want:       `{"user":{"name":"admin","password":"<redacted>"}}`,
+			wantHidden: []string{"s3cret"},
+		},
+		{
+			name:       "login response",
+			body:       `{"resp":{"status":"success"},"user":{"name":"admin","session":"0123abcd"}}`,
+			want:       `{"resp":{"status":"success"},"user":{"name":"admin","session":"<redacted>"}}`,
+			wantHidden: []string{"0123abcd"},
+		},
+		{
+			name:       "keys in arrays and other case",
+			body:       `[{"Password":"s3cret"},{"SESSION":{"id":"0123abcd"}}]`,
+			want:       `[{"Password":"<redacted>"},{"SESSION":"<redacted>"}]`,
+			wantHidden: []string{"s3cret", "0123abcd"},
+		},
+		{
+			name: "nothing to redact",
+			body: `{"deviceInfo":{"cpu":[{"usage":"7.13%"}]}}`,
+			want: `{"deviceInfo":{"cpu":[{"usage":"7.13%"}]}}`,
+		},
+		{
+			name:       "invalid JSON with a password",
+			body:       `{"user":{"name":"admin","password":"s3cret"`,
+			want:       "<redacted> (43 bytes, not valid JSON)",
+			wantHidden: []string{"s3cret"},
+		},
+		{
+			name:       "invalid JSON with a session",
+			body:       `user.session=0123abcd`,
+			want:       "<redacted> (21 bytes, not valid JSON)",
+			wantHidden: []string{"0123abcd"},
+		},
+		{
+			name: "invalid JSON without secrets",
+			body: `<html>Bad Gateway</html>`,
+			want: `<html>Bad Gateway</html>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactBody([]byte(tt.body))
+			if got != tt.want {
+				t.Errorf("redactBody() = %s, want %s", got, tt.want)
+			}
+			for _, secret := range tt.wantHidden {
+				if strings.Contains(got, secret) {
+					t.Errorf("redactBody() = %s contains %q", got, secret)
+				}
+			}
+		})
+	}
+}
@@ -1,14 +1,75 @@
 package netgear
 
-// DeviceInfoDetails represents individual uptime details returned by the deviceInfo endpoint
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// DeviceInfoDetails represents individual uptime, model and firmware details returned by the deviceInfo endpoint
 type DeviceInfoDetails struct {
-	Uptime string `json:"upTime"`
+	Uptime   string `json:"upTime"`
+	Model    string `json:"model"`
+	Firmware string `json:"swVer"`
 }
 
-// FanDetail represents information about an individual fan entry in the device
+// FanDetail represents information about an individual fan entry in the device. Status and MaxSpeed are only
+// reported by some firmware versions.
 type FanDetail struct {
-	Speed       float64 `json:"speed"`
-	Description string  `json:"desc"`
+	Speed       Number    `json:"speed"`
+	Description string    `json:"desc"`
+	Status      FanStatus `json:"state"`
+	MaxSpeed    Number    `json:"maxSpeed"`
+}
+
+// FanStatus is the state of a fan, e.g. "Operational", "Failed" or "Not Present". Like Number, decoding it never
+// fails, numbers are kept as text.
+type FanStatus string
+
+func (s *FanStatus) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		*s = FanStatus(data)
+		return nil
+	}
+	switch v := v.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = FanStatus(strings.TrimSpace(v))
+	default:
+		*s = FanStatus(fmt.Sprint(v))
+	}
+	return nil
+}
+
+// normalized returns the status in lower case without separators, e.g. "notpresent" for "Not Present"
+func (s FanStatus) normalized() string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '_' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, string(s))
+}
+
+// Present reports false if the switch reports the fan as not installed
+func (s FanStatus) Present() bool {
+	switch s.normalized() {
+	case "notpresent", "absent", "notinstalled", "removed":
+		return false
+	}
+	return true
+}
+
+// Failed reports whether the switch reports the fan as failed
+func (s FanStatus) Failed() bool {
+	switch s.normalized() {
+	case "failed", "failure", "fail", "fault", "error", "down", "notoperational", "notworking":
+		return true
+	}
+	return false
 }
 
 // Fan contains an array of all fan detail entries
@@ -18,9 +79,9 @@ type Fan struct {
 
 // SensorDetail represents a single thermal sensor reading
 type SensorDetail struct {
-	Description string  `json:"desc"`
-	Temperature float64 `json:"temp"`
-	MaxTemp     float64 `json:"maxTemp"`
+	Description string `json:"desc"`
+	Temperature Number `json:"temp"`
+	MaxTemp     Number `json:"maxTemp"`
 }
 
 // Sensor contains an array of sensor detail entries
@@ -28,33 +89,34 @@ type Sensor struct {
 	Details []SensorDetail `json:"details"`
 }
 
+// Usage represents the utilization of the CPU or the memory in percent. The field Usage used to be the string sent by
+// the switch, code passing it to StringPercentToFloat has to call its Float64 method instead.
+type Usage struct {
+	Usage Number `json:"usage"`
+	Unit  int32  `json:"unit"`
+}
+
 // DeviceInfo contains high level device information
 type DeviceInfo struct {
 	DeviceInfo struct {
 		Details []DeviceInfoDetails `json:"details"`
 		Fan     []Fan               `json:"fan"`
 		Sensor  []Sensor            `json:"sensor"`
-		Cpu     []struct {
-			Usage string `json:"usage"`
-			Unit  int32  `json:"unit"`
-		} `json:"cpu"`
-		Memory []struct {
-			Usage string `json:"usage"`
-			Unit  int32  `json:"unit"`
-		} `json:"memory"`
+		Cpu     []Usage             `json:"cpu"`
+		Memory  []Usage             `json:"memory"`
 	} `json:"deviceInfo"`
 }
 
 // PortStatisticRow contains measured per port traffic
 type PortStatisticRow struct {
-	Port        int     `json:"port"`
-	InTotalPkts float64 `json:"inTotalPkts"`
-	InDropPkts  float64 `json:"inDropPkts"`
-	InOctets    float64 `json:"inOctets"`
-
-	OutTotalPkts float64 `json:"outTotalPkts"`
-	OutDropPkts  float64 `json:"outDropPkts"`
-	OutOctets    float64 `json:"outOctets"`
+	Port        int    `json:"port"`
+	InTotalPkts Number `json:"inTotalPkts"`
+	InDropPkts  Number `json:"inDropPkts"`
+	InOctets    Number `json:"inOctets"`
+
+	OutTotalPkts Number `json:"outTotalPkts"`
+	OutDropPkts  Number `json:"outDropPkts"`
+	OutOctets    Number `json:"outOctets"`
 }
 
 // PortStatistics contains traffic statistics for all ports
@@ -0,0 +1,32 @@
+package netgear
+
+import "testing"
+
+func TestFanStatus(t *testing.T) {
+	tests := []struct {
+		status      FanStatus
+		wantPresent bool
+		wantFailed  bool
+	}{
+		{"Operational", true, false},
+		{"", true, false},
+		{"Failed", true, true},
+		{"FAULT", true, true},
+		{"Not Operational", true, true},
+		{"not-working", true, true},
+		{"Not Present", false, false},
+		{"not_installed", false, false},
+		{"Absent", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			if got := tt.status.Present(); got != tt.wantPresent {
+				t.Errorf("Present() = %v, want %v", got, tt.wantPresent)
+			}
+			if got := tt.status.Failed(); got != tt.wantFailed {
+				t.Errorf("Failed() = %v, want %v", got, tt.wantFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package netgear
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// uptimePartRe matches a single component of an uptime like "1 days", "31 mins" or "29s"
+	uptimePartRe = regexp.MustCompile(`(?i)(\d+)\s*(days?|d|hours?|hrs?|h|minutes?|mins?|m|seconds?|secs?|s)`)
+	// uptimeClockRe matches the time of day part of an uptime like "1 days 00:31:29"
+	uptimeClockRe = regexp.MustCompile(`\b(\d+):(\d{2}):(\d{2})\b`)
+	// uptimeSeparatorRe matches the text allowed between the components, e.g. in "1 day, 2 hours and 3 minutes"
+	uptimeSeparatorRe = regexp.MustCompile(`(?i),|\band\b`)
+)
+
+// ParseUptime parses the uptime reported by the switch, e.g. "1 days, 0 hrs, 31 mins, 29 secs", "1d 2h 3m 4s",
+// "1 days 00:31:29" or a number of seconds. Uptimes containing any other text are rejected, so unknown units like
+// months are not silently ignored.
+func ParseUptime(uptime string) (time.Duration, error) {
+	s := strings.TrimSpace(uptime)
+	if seconds, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	var d time.Duration
+	found := false
+	if m := uptimeClockRe.FindStringSubmatch(s); m != nil {
+		found = true
+		for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+			n, _ := strconv.Atoi(m[i+1])
+			d += time.Duration(n) * unit
+		}
+		s = strings.Replace(s, m[0], "", 1)
+	}
+
+	for _, m := range uptimePartRe.FindAllStringSubmatch(s, -1) {
+		found = true
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid uptime %q: %w", uptime, err)
+		}
+		var unit time.Duration
+		switch strings.ToLower(m[2])[0] {
+		case 'd':
+			unit = 24 * time.Hour
+		case 'h':
+			unit = time.Hour
+		case 'm':
+			unit = time.Minute
+		default:
+			unit = time.Second
+		}
+		d += time.Duration(n) * unit
+	}
+
+	if !found {
+		return 0, fmt.Errorf("invalid uptime %q", uptime)
+	}
+	rest := uptimeSeparatorRe.ReplaceAllString(uptimePartRe.ReplaceAllString(s, ""), " ")
+	if extra := strings.Fields(rest); len(extra) > 0 {
+		return 0, fmt.Errorf("invalid uptime %q: unexpected %q", uptime, strings.Join(extra, " "))
+	}
+	return d, nil
+}
@@ -0,0 +1,45 @@
+package netgear
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUptime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		// the format of the AV Line firmware
+		{"1 days, 0 hrs, 31 mins, 29 secs", 24*time.Hour + 31*time.Minute + 29*time.Second, false},
+		{"0 days, 0 hrs, 0 mins, 5 secs", 5 * time.Second, false},
+		{"1 day, 1 hr, 1 min, 1 sec", 25*time.Hour + time.Minute + time.Second, false},
+		{"1d 2h 3m 4s", 26*time.Hour + 3*time.Minute + 4*time.Second, false},
+		{"1D2H3M4S", 26*time.Hour + 3*time.Minute + 4*time.Second, false},
+		{"2 days, 3 hours and 4 minutes", 51*time.Hour + 4*time.Minute, false},
+		{"12 seconds", 12 * time.Second, false},
+		{"1 days 00:31:29", 24*time.Hour + 31*time.Minute + 29*time.Second, false},
+		{"100:00:01", 100*time.Hour + time.Second, false},
+		{"88289", 88289 * time.Second, false},
+		{"  88289 ", 88289 * time.Second, false},
+		{"", 0, true},
+		{"unknown", 0, true},
+		{"1 day 2 months", 0, true},
+		{"1 days, 0 hrs, about 31 mins", 0, true},
+		{"5 weeks", 0, true},
+		{"-5", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseUptime(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseUptime(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseUptime(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package netgear
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// apiPath is the path of the API below the base URL of a switch
+const apiPath = "/api/v1"
+
+// ParseBaseURL normalizes the address of a switch and returns the URL of its API. Accepted are host names and IP
+// addresses with an optional port, e.g. "192.0.2.10", "switch.example.com:8443", "2001:db8::1" or
+// "[2001:db8::1]:8443", as well as http and https URLs. The path of a URL is kept as prefix, so switches behind a
+// reverse proxy can be reached using e.g. "https://proxy.example.com/switches/foo". Addresses without a scheme use
+// http.
+func ParseBaseURL(raw string) (*url.URL, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return nil, fmt.Errorf("no hostname given")
+	}
+
+	if !strings.Contains(s, "://") {
+		host, path, _ := strings.Cut(s, "/")
+		// IPv6 addresses have to be enclosed in brackets to be used in a URL
+		if addr, err := netip.ParseAddr(host); err == nil && addr.Is6() {
+			host = "[" + strings.ReplaceAll(addr.String(), "%", "%25") + "]"
+		}
+		s = "http://" + host
+		if path != "" {
+			s += "/" + path
+		}
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", raw, err)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: unsupported scheme %q (use http or https)", raw, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid base URL %q: no hostname", raw)
+	}
+	if port := u.Port(); port != "" {
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return nil, fmt.Errorf("invalid base URL %q: invalid port %q", raw, port)
+		}
+	} else if strings.HasSuffix(u.Host, ":") {
+		return nil, fmt.Errorf("invalid base URL %q: empty port", raw)
+	}
+	if u.User != nil {
+		// don't repeat the URL, it contains a password
+		return nil, fmt.Errorf("invalid base URL: credentials have to be given using the username and password")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid base URL %q: query and fragment are not supported", raw)
+	}
+
+	// the API path may already be part of the URL, e.g. when it was copied from a request
+	u.Path = strings.TrimSuffix(strings.TrimRight(u.Path, "/"), apiPath) + apiPath
+	u.RawPath = ""
+	return u, nil
+}